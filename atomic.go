@@ -0,0 +1,55 @@
+package envdecode
+
+import "reflect"
+
+// isAtomicType reports whether t is one of the types of sync/atomic,
+// such as atomic.Int64, atomic.Bool or atomic.Value, whose values are
+// set with Store and read with Load.
+func isAtomicType(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t.PkgPath() != "sync/atomic" {
+		return false
+	}
+	pt := reflect.PtrTo(t)
+	store, ok := pt.MethodByName("Store")
+	if !ok || store.Type.NumIn() != 2 {
+		return false
+	}
+	_, ok = pt.MethodByName("Load")
+	return ok
+}
+
+// decodeAtomic decodes env into the addressable atomic value f and
+// stores it, so that readers calling Load see either the old value or
+// the new one.  An atomic.Value is decoded into the type of the value
+// it holds, or as a string if it is empty.  A value which fails to
+// decode is not stored.
+func (d *decodeState) decodeAtomic(f reflect.Value, env string, opts tagOptions, strict bool) error {
+	store := f.Addr().MethodByName("Store")
+	t := store.Type().In(0)
+	if t.Kind() == reflect.Interface {
+		t = reflect.TypeOf("")
+		if cur := atomicLoad(f); cur.IsValid() && !cur.IsNil() {
+			t = cur.Elem().Type()
+		}
+	}
+
+	v := reflect.New(t).Elem()
+	if err := d.decodeValue(v, env, opts, true); err != nil {
+		if strict {
+			return err
+		}
+		return nil
+	}
+	store.Call([]reflect.Value{v})
+	return nil
+}
+
+// atomicLoad returns the value held by the atomic value f.
+func atomicLoad(f reflect.Value) reflect.Value {
+	if !f.CanAddr() {
+		cp := reflect.New(f.Type()).Elem()
+		cp.Set(f)
+		f = cp
+	}
+	return f.Addr().MethodByName("Load").Call(nil)[0]
+}
@@ -0,0 +1,111 @@
+package envdecode
+
+import (
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testConfigAtomic struct {
+	Limit   atomic.Int64  `env:"TEST_ATOMIC_LIMIT,default=100"`
+	Debug   atomic.Bool   `env:"TEST_ATOMIC_DEBUG"`
+	Level   *atomic.Int32 `env:"TEST_ATOMIC_LEVEL"`
+	Name    atomic.Value  `env:"TEST_ATOMIC_NAME"`
+	Timeout atomic.Value  `env:"TEST_ATOMIC_TIMEOUT,strict"`
+}
+
+func TestDecodeAtomic(t *testing.T) {
+	os.Setenv("TEST_ATOMIC_DEBUG", "true")
+	os.Setenv("TEST_ATOMIC_LEVEL", "3")
+	os.Setenv("TEST_ATOMIC_NAME", "api")
+	os.Setenv("TEST_ATOMIC_TIMEOUT", "5s")
+	defer os.Unsetenv("TEST_ATOMIC_DEBUG")
+	defer os.Unsetenv("TEST_ATOMIC_LEVEL")
+	defer os.Unsetenv("TEST_ATOMIC_NAME")
+	defer os.Unsetenv("TEST_ATOMIC_TIMEOUT")
+
+	var tc testConfigAtomic
+	tc.Timeout.Store(time.Second)
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Limit.Load() != 100 || !tc.Debug.Load() || tc.Level.Load() != 3 {
+		t.Fatalf("Unexpected values %d, %v, %d", tc.Limit.Load(), tc.Debug.Load(), tc.Level.Load())
+	}
+	if tc.Name.Load() != "api" || tc.Timeout.Load() != 5*time.Second {
+		t.Fatalf("Unexpected values %v, %v", tc.Name.Load(), tc.Timeout.Load())
+	}
+
+	// The values are updated in place.
+	level := tc.Level
+	os.Setenv("TEST_ATOMIC_LEVEL", "4")
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Level != level || level.Load() != 4 {
+		t.Fatalf("Expected the level to be updated in place, got %d", level.Load())
+	}
+
+	os.Setenv("TEST_ATOMIC_TIMEOUT", "soon")
+	if err := Decode(&tc); err == nil {
+		t.Fatal("Expected an error for an invalid duration")
+	}
+	if tc.Timeout.Load() != 5*time.Second {
+		t.Fatalf("Expected the timeout to be kept, got %v", tc.Timeout.Load())
+	}
+
+	cfg, err := Export(&tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg[0].EnvVar != "TEST_ATOMIC_DEBUG" || cfg[0].Value != "true" || cfg[2].Value != "100" || cfg[4].Value != "5s" {
+		t.Fatalf("Unexpected export %+v %+v %+v", cfg[0], cfg[2], cfg[4])
+	}
+}
+
+type testConfigDynamic struct {
+	Addr  string       `env:"TEST_DYNAMIC_ADDR,required"`
+	Limit atomic.Int64 `env:"TEST_DYNAMIC_LIMIT,dynamic"`
+
+	Logging struct {
+		Debug atomic.Bool `env:"TEST_DYNAMIC_DEBUG,dynamic"`
+	}
+}
+
+func TestDecodeDynamic(t *testing.T) {
+	os.Setenv("TEST_DYNAMIC_ADDR", ":8080")
+	os.Setenv("TEST_DYNAMIC_LIMIT", "10")
+	defer os.Unsetenv("TEST_DYNAMIC_ADDR")
+	defer os.Unsetenv("TEST_DYNAMIC_LIMIT")
+
+	var tc testConfigDynamic
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("TEST_DYNAMIC_ADDR", ":9090")
+	os.Setenv("TEST_DYNAMIC_LIMIT", "20")
+	os.Setenv("TEST_DYNAMIC_DEBUG", "true")
+	defer os.Unsetenv("TEST_DYNAMIC_DEBUG")
+	if err := DecodeDynamic(&tc); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Addr != ":8080" || tc.Limit.Load() != 20 || !tc.Logging.Debug.Load() {
+		t.Fatalf("Unexpected config %s, %d, %v", tc.Addr, tc.Limit.Load(), tc.Logging.Debug.Load())
+	}
+	if err := ValidateStruct(&tc); err != nil {
+		t.Fatal(err)
+	}
+
+	type notAtomic struct {
+		Limit int64 `env:"TEST_DYNAMIC_LIMIT,dynamic"`
+	}
+	if err := DecodeDynamic(&notAtomic{}); err == nil || !strings.Contains(err.Error(), "not of a sync/atomic type") {
+		t.Fatalf("Expected an error for a non-atomic dynamic field, got %v", err)
+	}
+	if err := ValidateStruct(&notAtomic{}); err == nil || !strings.Contains(err.Error(), "not of a sync/atomic type") {
+		t.Fatalf("Expected an error for a non-atomic dynamic field, got %v", err)
+	}
+}
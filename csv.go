@@ -0,0 +1,103 @@
+package envdecode
+
+import (
+	"encoding/csv"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// isCSVType reports whether t is a slice of structs, or of pointers to
+// them, as fields tagged ",csv" must be.
+func isCSVType(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && derefType(t.Elem()).Kind() == reflect.Struct
+}
+
+// decodeCSV decodes env, holding CSV records one per line, or separated
+// by opts.separator if it is a single line, into the addressable slice
+// of structs f.  Columns are matched with the exported fields of the
+// struct in order, or by name if opts.csvHeader says the first record is
+// a header.
+func (d *decodeState) decodeCSV(f reflect.Value, env string, opts tagOptions) error {
+	if !isCSVType(f.Type()) {
+		return fmt.Errorf("csv requires a slice of structs, not %s", f.Type())
+	}
+	elem := f.Type().Elem()
+	st := derefType(elem)
+
+	if !strings.Contains(env, "\n") {
+		env = strings.Replace(env, opts.separator, "\n", -1)
+	}
+	r := csv.NewReader(strings.NewReader(env))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	var columns []int
+	for i := 0; i < st.NumField(); i++ {
+		if st.Field(i).PkgPath == "" {
+			columns = append(columns, i)
+		}
+	}
+	if opts.csvHeader && len(records) > 0 {
+		header := records[0]
+		records = records[1:]
+		if columns, err = csvColumns(st, header); err != nil {
+			return err
+		}
+	}
+
+	slice := reflect.MakeSlice(f.Type(), len(records), len(records))
+	for n, record := range records {
+		if len(record) > len(columns) {
+			return fmt.Errorf("record %d has %d columns, expected at most %d", n+1, len(record), len(columns))
+		}
+
+		e := slice.Index(n)
+		if elem.Kind() == reflect.Ptr {
+			e.Set(reflect.New(st))
+			e = e.Elem()
+		}
+		for i, cell := range record {
+			if cell = strings.TrimSpace(cell); cell == "" {
+				continue
+			}
+			sf := st.Field(columns[i])
+			if err := d.decodeElem(e.Field(columns[i]), cell, tagOptions{lenient: opts.lenient}); err != nil {
+				return fmt.Errorf("record %d: %s: %v", n+1, sf.Name, err)
+			}
+		}
+	}
+
+	f.Set(slice)
+	return nil
+}
+
+// csvColumns returns the indexes of the fields of the struct type t
+// named by header, matching the name in a field's "csv" tag, or failing
+// that its name, in any case.
+func csvColumns(t reflect.Type, header []string) ([]int, error) {
+	columns := make([]int, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		columns[i] = -1
+		for j := 0; j < t.NumField(); j++ {
+			sf := t.Field(j)
+			if sf.PkgPath != "" {
+				continue
+			}
+			tag := strings.Split(sf.Tag.Get("csv"), ",")[0]
+			if tag == name || (tag == "" && strings.EqualFold(sf.Name, name)) {
+				columns[i] = j
+				break
+			}
+		}
+		if columns[i] < 0 {
+			return nil, fmt.Errorf("no field for column %q", name)
+		}
+	}
+	return columns, nil
+}
@@ -0,0 +1,111 @@
+package envdecode
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact fixed-point decimal number, such as a price, which
+// would lose precision as a float64.  It is decoded from values such as
+// "19.99", "-0.005" or "1.5e3", keeping the number of digits after the
+// decimal point given.  The zero value is 0.
+//
+// Types from other packages, such as github.com/shopspring/decimal, are
+// supported through their UnmarshalText methods and need no help.
+type Decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+// maxDecimalExponent bounds the exponent of a Decimal, which would
+// otherwise let a value such as "1e999999999" take unbounded time and
+// memory to decode.
+const maxDecimalExponent = 1000
+
+// Decode implements Decoder.
+func (d *Decimal) Decode(s string) error {
+	orig := s
+	s = strings.TrimSpace(s)
+
+	exp := 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return fmt.Errorf("invalid decimal %q", orig)
+		}
+		if e > maxDecimalExponent || e < -maxDecimalExponent {
+			return fmt.Errorf("decimal %q is out of range", orig)
+		}
+		s, exp = s[:i], e
+	}
+
+	sign := ""
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		sign, s = s[:1], s[1:]
+	}
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if whole+frac == "" || strings.Trim(whole+frac, "0123456789") != "" {
+		return fmt.Errorf("invalid decimal %q", orig)
+	}
+
+	unscaled, ok := new(big.Int).SetString(sign+whole+frac, 10)
+	if !ok {
+		return fmt.Errorf("invalid decimal %q", orig)
+	}
+	scale := len(frac) - exp
+	if scale < 0 {
+		unscaled.Mul(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-scale)), nil))
+		scale = 0
+	}
+
+	d.unscaled, d.scale = unscaled, scale
+	return nil
+}
+
+// Unscaled returns the digits of d as an integer, such that d equals
+// Unscaled() / 10^Scale().
+func (d Decimal) Unscaled() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(d.unscaled)
+}
+
+// Scale returns the number of digits after the decimal point.
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// Rat returns d as a rational number.
+func (d Decimal) Rat() *big.Rat {
+	denom := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.scale)), nil)
+	return new(big.Rat).SetFrac(d.Unscaled(), denom)
+}
+
+// Float64 returns the float64 nearest to d.
+func (d Decimal) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+// String formats d with Scale() digits after the decimal point.
+func (d Decimal) String() string {
+	u := d.Unscaled()
+	neg := u.Sign() < 0
+	digits := u.Abs(u).String()
+	if d.scale > 0 {
+		if len(digits) <= d.scale {
+			digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+	}
+	if neg {
+		return "-" + digits
+	}
+	return digits
+}
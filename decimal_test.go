@@ -0,0 +1,62 @@
+package envdecode
+
+import (
+	"math/big"
+	"os"
+	"testing"
+)
+
+func TestDecimal(t *testing.T) {
+	var tc struct {
+		Price Decimal `env:"TEST_DECIMAL"`
+	}
+
+	tests := []struct {
+		value    string
+		expected string
+		unscaled int64
+		scale    int
+	}{
+		{"19.99", "19.99", 1999, 2},
+		{"-0.005", "-0.005", -5, 3},
+		{"+7", "7", 7, 0},
+		{".5", "0.5", 5, 1},
+		{"1.5e3", "1500", 1500, 0},
+		{"1.25E-2", "0.0125", 125, 4},
+		{"0.10", "0.10", 10, 2},
+	}
+
+	defer os.Unsetenv("TEST_DECIMAL")
+	for _, test := range tests {
+		os.Setenv("TEST_DECIMAL", test.value)
+		if err := StrictDecode(&tc); err != nil {
+			t.Fatalf("Decoding %q: %v", test.value, err)
+		}
+		if s := tc.Price.String(); s != test.expected {
+			t.Fatalf("Expected %q to decode as %s, got %s", test.value, test.expected, s)
+		}
+		if tc.Price.Unscaled().Int64() != test.unscaled || tc.Price.Scale() != test.scale {
+			t.Fatalf("Expected %q to be %d scaled by %d, got %s scaled by %d", test.value, test.unscaled, test.scale, tc.Price.Unscaled(), tc.Price.Scale())
+		}
+	}
+
+	// 0.1 + 0.2 is exact.
+	var a, b Decimal
+	a.Decode("0.1")
+	b.Decode("0.2")
+	if sum := new(big.Rat).Add(a.Rat(), b.Rat()); sum.Cmp(big.NewRat(3, 10)) != 0 {
+		t.Fatalf("Expected 3/10, got %s", sum)
+	}
+
+	for _, value := range []string{"", "-", "1.2.3", "abc", "1e", "0x10", "1e99999999", "1e-99999999"} {
+		var d Decimal
+		if err := d.Decode(value); err == nil {
+			t.Fatalf("Expected an error decoding %q", value)
+		}
+	}
+
+	var zero Decimal
+	if zero.String() != "0" || zero.Float64() != 0 {
+		t.Fatalf("Expected the zero value to be 0, got %s", zero)
+	}
+}
@@ -0,0 +1,125 @@
+package envdecode
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Tag dialects understood by CompareDialects.
+const (
+	DialectEnvconfig = "envconfig"    // github.com/kelseyhightower/envconfig
+	DialectCaarlos0  = "caarlos0/env" // github.com/caarlos0/env
+)
+
+// A DialectDifference describes a field whose tags would be interpreted
+// differently by another environment decoding package.
+type DialectDifference struct {
+	Field   string
+	Dialect string
+	Message string
+}
+
+func (d *DialectDifference) String() string {
+	return fmt.Sprintf("%s (%s): %s", d.Field, d.Dialect, d.Message)
+}
+
+// caarlos0Options are the "env" tag options understood by caarlos0/env.
+var caarlos0Options = map[string]bool{
+	"required": true,
+	"file":     true,
+	"unset":    true,
+	"notEmpty": true,
+	"expand":   true,
+	"init":     true,
+}
+
+// CompareDialects inspects the struct type of target and reports the
+// fields whose tags would behave differently under envconfig or
+// caarlos0/env than under envdecode, to help migrations in either
+// direction.  target must be a struct or a pointer to one; only its
+// type is examined.
+func CompareDialects(target interface{}) ([]*DialectDifference, error) {
+	t, err := structType(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []*DialectDifference
+	report := func(field, dialect, format string, args ...interface{}) {
+		diffs = append(diffs, &DialectDifference{
+			Field:   field,
+			Dialect: dialect,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	walkFields(t, func(parents []reflect.StructField, sf reflect.StructField, nested bool) {
+		if nested || sf.PkgPath != "" {
+			return
+		}
+
+		path := fieldPath(parents, sf)
+		prefix := ""
+		for _, p := range parents {
+			prefix = envconfigPrefix(p, prefix)
+		}
+		ecName, _ := envconfigKey(sf, prefix)
+		isSlice := sf.Type.Kind() == reflect.Slice && sf.Type.Elem().Kind() != reflect.Uint8
+
+		opts, ok := fieldTag(sf)
+		if ok && opts.name != "" {
+			opts.name = namePrefix(parents) + opts.name
+		}
+		if !ok {
+			if !envconfigIgnored(sf) {
+				report(path, DialectEnvconfig, "not decoded by envdecode without an env tag; envconfig reads %s", ecName)
+			}
+			if _, ok := sf.Tag.Lookup("default"); ok {
+				report(path, DialectEnvconfig, "default tag is ignored by envdecode; use envDefault or \",default=\"")
+			}
+			return
+		}
+
+		// envconfig ignores the env tag entirely.
+		if ecName != opts.name {
+			report(path, DialectEnvconfig, "envdecode reads %s but envconfig reads %s", opts.name, ecName)
+		}
+		if opts.hasDefault || opts.required {
+			report(path, DialectEnvconfig, "envdecode default and required settings are ignored by envconfig; use default and required tags")
+		}
+
+		parts := splitTag(sf.Tag.Get("env"))
+		for _, o := range parts[1:] {
+			key := o
+			if i := strings.IndexByte(o, '='); i >= 0 {
+				key = o[:i]
+			}
+			switch {
+			case key == "loadfile":
+				report(path, DialectCaarlos0, "option %q is spelled \"file\" in caarlos0/env", o)
+			case !caarlos0Options[key]:
+				report(path, DialectCaarlos0, "option %q is not supported by caarlos0/env", o)
+			}
+		}
+		if _, ok := sf.Tag.Lookup("envRequired"); ok {
+			report(path, DialectCaarlos0, "envRequired tag is ignored by caarlos0/env; use \",required\"")
+		}
+
+		if isSlice {
+			sep := opts.separator
+			if s, ok := sf.Tag.Lookup("envSeparator"); ok {
+				if s != sep {
+					report(path, DialectCaarlos0, "envSeparator %q differs from envdecode's separator %q", s, sep)
+				}
+			} else if sep != "," {
+				report(path, DialectCaarlos0, "slice elements are separated by %q in envdecode but \",\" in caarlos0/env", sep)
+			}
+			if sep != "," {
+				report(path, DialectEnvconfig, "slice elements are separated by %q in envdecode but \",\" in envconfig", sep)
+			}
+		}
+	})
+
+	return diffs, nil
+}
@@ -0,0 +1,69 @@
+package envdecode
+
+import (
+	"testing"
+)
+
+type testConfigDialects struct {
+	Host     string   `env:"HOST"`
+	Port     int      `env:"SERVER_PORT,default=8080"`
+	Hosts    []string `env:"HOSTS"`
+	CSV      []string `env:"CSV" envSeparator:","`
+	Bundle   []byte   `env:"BUNDLE,loadfile"`
+	Token    string   `env:"TOKEN" envRequired:"true"`
+	Untagged string
+	Legacy   string `default:"x" ignored:"true"`
+
+	Database struct {
+		URL string `env:"DATABASE_URL,required"`
+	}
+}
+
+func TestCompareDialects(t *testing.T) {
+	diffs, err := CompareDialects(&testConfigDialects{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	have := map[string]int{}
+	for _, d := range diffs {
+		have[d.Field+" "+d.Dialect]++
+	}
+
+	expected := map[string]int{
+		// HOST, TOKEN, BUNDLE and DATABASE_URL match envconfig's
+		// field name derived names.
+		"Port envconfig":         2,
+		"Port caarlos0/env":      1,
+		"Hosts envconfig":        1,
+		"Hosts caarlos0/env":     1,
+		"CSV envconfig":          1,
+		"CSV caarlos0/env":       1,
+		"Bundle caarlos0/env":    1,
+		"Token envconfig":        1,
+		"Token caarlos0/env":     1,
+		"Untagged envconfig":     1,
+		"Legacy envconfig":       1,
+		"Database.URL envconfig": 1,
+	}
+
+	for k, n := range expected {
+		if have[k] != n {
+			t.Errorf("Expected %d differences for %s, got %d", n, k, have[k])
+		}
+	}
+	for k := range have {
+		if _, ok := expected[k]; !ok {
+			t.Errorf("Unexpected difference for %s", k)
+		}
+	}
+	if t.Failed() {
+		for _, d := range diffs {
+			t.Log(d)
+		}
+	}
+
+	if _, err := CompareDialects(42); err != ErrInvalidTarget {
+		t.Fatalf("Expected ErrInvalidTarget, got %v", err)
+	}
+}
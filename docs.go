@@ -0,0 +1,200 @@
+package envdecode
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"strings"
+)
+
+// Docs maps the paths of fields, such as "Database.URL", to their doc
+// comments, in the form used by the Field of ConfigInfo.
+type Docs map[string]string
+
+// ParseDocs parses the Go source files in dir, a package directory, and
+// returns the doc comments of the fields of the struct type typeName,
+// and of the structs nested within it that are declared in the same
+// package, so that documentation needn't be repeated in desc options.
+// A field's comment is the one above it or, failing that, the one
+// following it on the same line.  It is meant for documentation
+// generators run from the source tree, since comments aren't available
+// at run time.
+func ParseDocs(dir, typeName string) (Docs, error) {
+	docs := Docs{}
+	err := parseFields(dir, typeName, func(fset *token.FileSet, path string, field *ast.Field) {
+		doc := commentText(field.Doc)
+		if doc == "" {
+			doc = commentText(field.Comment)
+		}
+		if doc != "" {
+			docs[path] = doc
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// Positions maps the paths of fields, such as "Database.URL", to the
+// positions of their declarations, such as "config/config.go:42", in
+// the form used by the Position of ConfigInfo.
+type Positions map[string]string
+
+// ParsePositions parses the Go source files in dir, a package
+// directory, and returns the positions of the fields of the struct type
+// typeName, and of the structs nested within it that are declared in
+// the same package, so that diagnostics can say where a variable is
+// defined.  Like ParseDocs, it is meant for tools run from the source
+// tree, since positions aren't available at run time.  File names are
+// joined to dir.
+func ParsePositions(dir, typeName string) (Positions, error) {
+	positions := Positions{}
+	err := parseFields(dir, typeName, func(fset *token.FileSet, path string, field *ast.Field) {
+		pos := fset.Position(field.Pos())
+		positions[path] = fmt.Sprintf("%s:%d", pos.Filename, pos.Line)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return positions, nil
+}
+
+// parseFields parses the package in dir and calls fn with each field
+// of the struct type typeName, and of the structs nested within it that
+// are declared in the same package, along with its path.
+func parseFields(dir, typeName string, fn func(fset *token.FileSet, path string, field *ast.Field)) error {
+	notTest := func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, notTest, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("envdecode: parsing %s: %v", dir, err)
+	}
+
+	for _, pkg := range pkgs {
+		types := structTypes(pkg)
+		if st, ok := types[typeName]; ok {
+			walkStruct(st, "", types, map[string]bool{typeName: true}, func(path string, field *ast.Field) {
+				fn(fset, path, field)
+			})
+			return nil
+		}
+	}
+	return fmt.Errorf("envdecode: no struct type %s in %s", typeName, dir)
+}
+
+// structTypes returns the struct types declared at the top level of
+// pkg, by name.
+func structTypes(pkg *ast.Package) map[string]*ast.StructType {
+	types := map[string]*ast.StructType{}
+	for _, f := range pkg.Files {
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if st, ok := ts.Type.(*ast.StructType); ok {
+					types[ts.Name.Name] = st
+				}
+			}
+		}
+	}
+	return types
+}
+
+// walkStruct calls fn with the fields of st, whose path is path, and of
+// the structs nested within it, skipping the named types in walking.
+func walkStruct(st *ast.StructType, path string, types map[string]*ast.StructType, walking map[string]bool, fn func(path string, field *ast.Field)) {
+	for _, field := range st.Fields.List {
+		names := make([]string, len(field.Names))
+		for i, n := range field.Names {
+			names[i] = n.Name
+		}
+
+		typ := field.Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		if len(names) == 0 {
+			// An embedded field is named after its type.
+			switch t := typ.(type) {
+			case *ast.Ident:
+				names = []string{t.Name}
+			case *ast.SelectorExpr:
+				names = []string{t.Sel.Name}
+			}
+		}
+
+		for _, name := range names {
+			if path != "" {
+				name = path + "." + name
+			}
+			fn(name, field)
+
+			switch t := typ.(type) {
+			case *ast.StructType:
+				walkStruct(t, name, types, walking, fn)
+			case *ast.Ident:
+				if nested, ok := types[t.Name]; ok && !walking[t.Name] {
+					walking[t.Name] = true
+					walkStruct(nested, name, types, walking, fn)
+					delete(walking, t.Name)
+				}
+			}
+		}
+	}
+}
+
+// commentText returns the text of c as a single line.
+func commentText(c *ast.CommentGroup) string {
+	return strings.Join(strings.Fields(c.Text()), " ")
+}
+
+// Apply sets the Description of each of cfg that has none to the doc
+// comment of its field.
+func (docs Docs) Apply(cfg []*ConfigInfo) {
+	for _, ci := range cfg {
+		if ci.Description == "" {
+			ci.Description = docs[ci.Field]
+		}
+	}
+}
+
+// ApplyGroup sets the Description of g, of its values and of the groups
+// nested within it, where they have none, to the doc comments of their
+// fields.
+func (docs Docs) ApplyGroup(g *ConfigGroup) {
+	if g.Description == "" && g.Field != "" {
+		g.Description = docs[g.Field]
+	}
+	docs.Apply(g.Values)
+	for _, sub := range g.Groups {
+		docs.ApplyGroup(sub)
+	}
+}
+
+// Apply sets the Position of each of cfg that has none to the position
+// of its field.
+func (positions Positions) Apply(cfg []*ConfigInfo) {
+	for _, ci := range cfg {
+		if ci.Position == "" {
+			ci.Position = positions[ci.Field]
+		}
+	}
+}
+
+// ApplyGroup sets the Position of the values of g, and of the groups
+// nested within it, where they have none, to the positions of their
+// fields.
+func (positions Positions) ApplyGroup(g *ConfigGroup) {
+	positions.Apply(g.Values)
+	for _, sub := range g.Groups {
+		positions.ApplyGroup(sub)
+	}
+}
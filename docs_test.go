@@ -0,0 +1,85 @@
+package envdecode
+
+import (
+	"net/url"
+	"testing"
+)
+
+type testConfigDocs struct {
+	Host string `env:"HOST"`
+	Port int    `env:"PORT,desc=Port to bind"`
+
+	Database struct {
+		URL *url.URL `env:"DATABASE_URL"`
+	}
+}
+
+func TestParseDocs(t *testing.T) {
+	docs, err := ParseDocs("testdata/docs", "Config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Docs{
+		"Host":         "Host is the name the server listens on.",
+		"Port":         "Port to listen on",
+		"Database":     "Database settings",
+		"Database.URL": "URL of the database",
+		"Cache.TTL":    "TTL of entries",
+	}
+	if len(docs) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, docs)
+	}
+	for path, doc := range expected {
+		if docs[path] != doc {
+			t.Fatalf("Expected %s to be documented as %q, got %q", path, doc, docs[path])
+		}
+	}
+
+	g, err := ExportGroups(&testConfigDocs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs.ApplyGroup(g)
+	if g.Values[0].Description != "Host is the name the server listens on." || g.Values[1].Description != "Port to bind" {
+		t.Fatalf("Unexpected descriptions %+v, %+v", g.Values[0], g.Values[1])
+	}
+	if db := g.Groups[0]; db.Description != "Database settings" || db.Values[0].Description != "URL of the database" {
+		t.Fatalf("Unexpected database group %+v", db)
+	}
+
+	if _, err := ParseDocs("testdata/docs", "Missing"); err == nil {
+		t.Fatal("Expected an error for a missing type")
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	positions, err := ParsePositions("testdata/docs", "Config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"Host":         "testdata/docs/config.go:9",
+		"Database.URL": "testdata/docs/config.go:16",
+		"Cache.TTL":    "testdata/docs/config.go:28",
+	}
+	for path, pos := range expected {
+		if positions[path] != pos {
+			t.Fatalf("Expected %s at %q, got %q", path, pos, positions[path])
+		}
+	}
+
+	g, err := ExportGroups(&testConfigDocs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	positions.ApplyGroup(g)
+	if g.Values[0].Position != "testdata/docs/config.go:9" || g.Groups[0].Values[0].Position != "testdata/docs/config.go:16" {
+		t.Fatalf("Unexpected positions %+v, %+v", g.Values[0], g.Groups[0].Values[0])
+	}
+
+	if _, err := ParsePositions("testdata/docs", "Missing"); err == nil {
+		t.Fatal("Expected an error for a missing type")
+	}
+}
@@ -0,0 +1,33 @@
+package envdecode
+
+import "sort"
+
+// Encode returns the configuration held by target as the variables that
+// would decode to it, in the form of os.Environ, sorted by name: the
+// counterpart to DecodeFrom, for handing a configuration on to a child
+// process or saving it for later.  Values are formatted as by Export,
+// but secrets are included as they are, so the result must be treated
+// as carefully as the secrets themselves.  Variables with empty values
+// are left out, as are all but the first field reading a variable.  Of
+// the options, only WithSliceSeparator and WithTemplateData have an
+// effect.
+func Encode(target interface{}, opts ...Option) ([]string, error) {
+	g, err := exportGroups(target, newDecodeState(opts), revealSecrets)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := g.All()
+	sort.Sort(ConfigInfoSlice(cfg))
+
+	var env []string
+	seen := map[string]bool{}
+	for _, ci := range cfg {
+		if ci.Value == "" || seen[ci.EnvVar] {
+			continue
+		}
+		seen[ci.EnvVar] = true
+		env = append(env, ci.EnvVar+"="+ci.Value)
+	}
+	return env, nil
+}
@@ -0,0 +1,82 @@
+package envdecode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testPair struct {
+	A, B string
+}
+
+func (p *testPair) Decode(s string) error {
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return nil
+	}
+	p.A, p.B = s[:i], s[i+1:]
+	return nil
+}
+
+func (p testPair) EncodeEnv() (string, error) {
+	return p.A + "/" + p.B, nil
+}
+
+func (p testPair) String() string {
+	return p.A + " and " + p.B
+}
+
+type testConfigEncode struct {
+	Pair     testPair   `env:"TEST_ENCODE_PAIR"`
+	Pairs    []testPair `env:"TEST_ENCODE_PAIRS"`
+	Name     string     `env:"TEST_ENCODE_NAME"`
+	Password string     `env:"TEST_ENCODE_PASSWORD,secret"`
+	Unset    string     `env:"TEST_ENCODE_UNSET"`
+}
+
+func TestEncode(t *testing.T) {
+	env := []string{
+		"TEST_ENCODE_PAIR=a/b",
+		"TEST_ENCODE_PAIRS=c/d;e/f",
+		"TEST_ENCODE_NAME=app",
+		"TEST_ENCODE_PASSWORD=hunter2",
+	}
+
+	var tc testConfigEncode
+	if err := DecodeFrom(&tc, env); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Export(&tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ci := range cfg {
+		if ci.EnvVar == "TEST_ENCODE_PAIR" && ci.Value != "a/b" {
+			t.Fatalf("Expected EncodeEnv to take precedence over String, got %q", ci.Value)
+		}
+	}
+
+	got, err := Encode(&tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"TEST_ENCODE_NAME=app",
+		"TEST_ENCODE_PAIR=a/b",
+		"TEST_ENCODE_PAIRS=c/d;e/f",
+		"TEST_ENCODE_PASSWORD=hunter2",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %q, got %q", expected, got)
+	}
+
+	var again testConfigEncode
+	if err := DecodeFrom(&again, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, tc) {
+		t.Fatalf("Expected %+v, got %+v", tc, again)
+	}
+}
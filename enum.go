@@ -0,0 +1,45 @@
+package envdecode
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// RegisterEnum registers a decoder for the enumerated type T that
+// accepts the names in values, such as "debug" for a LogLevel
+// constant, without regard to case, and rejects any other value.
+// Export reports values of T by their names; a value with several
+// names is reported by the first in sorted order.  Registering T again
+// replaces its names.
+func RegisterEnum[T comparable](values map[string]T) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	byValue := make(map[T]string, len(values))
+	for _, name := range names {
+		if _, ok := byValue[values[name]]; !ok {
+			byValue[values[name]] = name
+		}
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	RegisterTypeDecoder(t, func(s string) (interface{}, error) {
+		v, ok := lookupName(values, strings.TrimSpace(s))
+		if !ok {
+			return nil, fmt.Errorf("invalid value %q; expected one of %s", s, strings.Join(names, ", "))
+		}
+		return v, nil
+	})
+	registerTypeFormatter(t, func(f reflect.Value) string {
+		v := f.Interface().(T)
+		if name, ok := byValue[v]; ok {
+			return name
+		}
+		return fmt.Sprint(v)
+	})
+}
@@ -0,0 +1,73 @@
+package envdecode
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+type testLogLevel int
+
+const (
+	testLevelDebug testLogLevel = iota
+	testLevelInfo
+	testLevelError
+)
+
+type testConfigEnum struct {
+	Level  testLogLevel   `env:"TEST_ENUM_LEVEL,default=info"`
+	Levels []testLogLevel `env:"TEST_ENUM_LEVELS"`
+}
+
+func TestRegisterEnum(t *testing.T) {
+	RegisterEnum(map[string]testLogLevel{
+		"debug": testLevelDebug,
+		"info":  testLevelInfo,
+		"error": testLevelError,
+		"err":   testLevelError,
+	})
+
+	var tc testConfigEnum
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Level != testLevelInfo {
+		t.Fatalf("Expected the default level, got %d", tc.Level)
+	}
+
+	os.Setenv("TEST_ENUM_LEVEL", "ERROR")
+	os.Setenv("TEST_ENUM_LEVELS", "debug;err")
+	defer os.Unsetenv("TEST_ENUM_LEVEL")
+	defer os.Unsetenv("TEST_ENUM_LEVELS")
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Level != testLevelError || len(tc.Levels) != 2 || tc.Levels[1] != testLevelError {
+		t.Fatalf("Unexpected config %+v", tc)
+	}
+
+	cfg, err := Export(&tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg[0].EnvVar != "TEST_ENUM_LEVEL" || cfg[0].Value != "err" {
+		t.Fatalf("Expected the level to be exported by name, got %+v", cfg[0])
+	}
+	tc.Level = 7
+	if cfg, _ := Export(&tc); cfg[0].Value != "7" {
+		t.Fatalf("Expected an unnamed level to be exported as a number, got %q", cfg[0].Value)
+	}
+
+	os.Setenv("TEST_ENUM_LEVEL", "verbose")
+	err = StrictDecode(&tc)
+	if err == nil || !strings.Contains(err.Error(), `invalid value "verbose"; expected one of debug, err, error, info`) {
+		t.Fatalf("Expected an error for an unknown level, got %v", err)
+	}
+
+	type badDefault struct {
+		Level testLogLevel `env:"TEST_ENUM_LEVEL,default=verbose"`
+	}
+	if err := ValidateStruct(&badDefault{}); err == nil {
+		t.Fatal("Expected an invalid default to be reported")
+	}
+}
@@ -0,0 +1,96 @@
+package envdecode
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// WithEnvconfigCompat decodes fields without an "env" tag following the
+// conventions of github.com/kelseyhightower/envconfig, so that structs
+// written for envconfig can be decoded while their tags are migrated.
+//
+// Such fields are read from PREFIX_NAME, where NAME is the upper-cased
+// "envconfig" tag or field name, split into words at case changes when
+// tagged split_words:"true".  A field with an "envconfig" tag also
+// falls back to the unprefixed tag value.  Nested structs extend the
+// prefix with their own name, except for embedded structs without an
+// "envconfig" tag.  The "default", "required" and "ignored" tags are
+// honored, and slices are separated by commas.  Fields with an "env"
+// tag are decoded as usual.
+func WithEnvconfigCompat(prefix string) Option {
+	return func(o *options) {
+		o.envconfig = true
+		o.envconfigPrefix = strings.ToUpper(prefix)
+	}
+}
+
+var (
+	gatherRegexp  = regexp.MustCompile("([^A-Z]+|[A-Z]+[^A-Z]+|[A-Z]+)")
+	acronymRegexp = regexp.MustCompile("([A-Z]+)([A-Z][^A-Z]+)")
+)
+
+// splitWords splits a Go identifier into words at case changes, keeping
+// acronyms together: "HTTPServerPort" becomes "HTTP", "Server", "Port".
+func splitWords(name string) []string {
+	var words []string
+	for _, m := range gatherRegexp.FindAllString(name, -1) {
+		if a := acronymRegexp.FindStringSubmatch(m); len(a) == 3 {
+			words = append(words, a[1], a[2])
+		} else {
+			words = append(words, m)
+		}
+	}
+	return words
+}
+
+func envconfigIgnored(sf reflect.StructField) bool {
+	ignored, _ := strconv.ParseBool(sf.Tag.Get("ignored"))
+	return ignored
+}
+
+// envconfigKey returns the upper-cased, prefixed variable name for sf
+// and the unprefixed alternative given by its "envconfig" tag, if any.
+func envconfigKey(sf reflect.StructField, prefix string) (key, alt string) {
+	key = sf.Tag.Get("envconfig")
+	alt = strings.ToUpper(key)
+	if key == "" {
+		key = sf.Name
+		if split, _ := strconv.ParseBool(sf.Tag.Get("split_words")); split {
+			key = strings.Join(splitWords(key), "_")
+		}
+	}
+	if prefix != "" {
+		key = prefix + "_" + key
+	}
+	return strings.ToUpper(key), alt
+}
+
+func envconfigTag(sf reflect.StructField, prefix string) (tagOptions, bool) {
+	if sf.PkgPath != "" || envconfigIgnored(sf) {
+		return tagOptions{}, false
+	}
+
+	name, alt := envconfigKey(sf, prefix)
+	opts := tagOptions{
+		name:      name,
+		altName:   alt,
+		separator: ",",
+		maxSize:   defaultMaxFileSize,
+	}
+	if def, ok := sf.Tag.Lookup("default"); ok {
+		opts.hasDefault = true
+		opts.defaultValue = def
+	}
+	opts.required, _ = strconv.ParseBool(sf.Tag.Get("required"))
+	return opts, true
+}
+
+func envconfigPrefix(sf reflect.StructField, prefix string) string {
+	if sf.Anonymous && sf.Tag.Get("envconfig") == "" {
+		return prefix
+	}
+	key, _ := envconfigKey(sf, prefix)
+	return key
+}
@@ -0,0 +1,111 @@
+package envdecode
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type EnvconfigEmbedded struct {
+	Region string
+}
+
+type testConfigEnvconfig struct {
+	EnvconfigEmbedded
+
+	Debug          bool
+	Port           int
+	Users          []string
+	Timeout        time.Duration
+	ManualOverride string `envconfig:"manual_override_1"`
+	DefaultVar     string `default:"foobar"`
+	RequiredVar    string `required:"true"`
+	IgnoredVar     string `ignored:"true"`
+	AutoSplitVar   string `split_words:"true"`
+	Tagged         string `env:"TEST_ENVCONFIG_TAGGED"`
+
+	Server struct {
+		Host string
+	}
+	Ignored struct {
+		Host string
+	} `ignored:"true"`
+}
+
+func TestEnvconfigCompat(t *testing.T) {
+	env := map[string]string{
+		"MYAPP_DEBUG":             "true",
+		"MYAPP_PORT":              "8080",
+		"MYAPP_USERS":             "rob,ken",
+		"MYAPP_TIMEOUT":           "3m",
+		"MANUAL_OVERRIDE_1":       "override",
+		"MYAPP_REQUIREDVAR":       "required",
+		"MYAPP_IGNOREDVAR":        "ignored",
+		"MYAPP_AUTO_SPLIT_VAR":    "split",
+		"MYAPP_REGION":            "us-east-1",
+		"MYAPP_SERVER_HOST":       "localhost",
+		"MYAPP_IGNORED_HOST":      "ignored",
+		"TEST_ENVCONFIG_TAGGED":   "tagged",
+		"MYAPP_MANUAL_OVERRIDE_1": "",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	var tc testConfigEnvconfig
+	if err := DecodeWithOptions(&tc, WithEnvconfigCompat("myapp")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !tc.Debug || tc.Port != 8080 || tc.Timeout != 3*time.Minute {
+		t.Fatalf("Unexpected primitive values %+v", tc)
+	}
+	if !reflect.DeepEqual(tc.Users, []string{"rob", "ken"}) {
+		t.Fatalf("Expected [rob ken], got %v", tc.Users)
+	}
+	if tc.ManualOverride != "override" {
+		t.Fatalf(`Expected "override", got %q`, tc.ManualOverride)
+	}
+	if tc.DefaultVar != "foobar" {
+		t.Fatalf(`Expected "foobar", got %q`, tc.DefaultVar)
+	}
+	if tc.RequiredVar != "required" {
+		t.Fatalf(`Expected "required", got %q`, tc.RequiredVar)
+	}
+	if tc.IgnoredVar != "" || tc.Ignored.Host != "" {
+		t.Fatalf("Expected ignored fields to be empty, got %+v", tc)
+	}
+	if tc.AutoSplitVar != "split" {
+		t.Fatalf(`Expected "split", got %q`, tc.AutoSplitVar)
+	}
+	if tc.Region != "us-east-1" {
+		t.Fatalf(`Expected "us-east-1", got %q`, tc.Region)
+	}
+	if tc.Server.Host != "localhost" {
+		t.Fatalf(`Expected "localhost", got %q`, tc.Server.Host)
+	}
+	if tc.Tagged != "tagged" {
+		t.Fatalf(`Expected "tagged", got %q`, tc.Tagged)
+	}
+
+	os.Unsetenv("MYAPP_REQUIREDVAR")
+	if err := DecodeWithOptions(&tc, WithEnvconfigCompat("myapp")); err == nil {
+		t.Fatal("Expected an error for a missing required variable")
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	cases := map[string][]string{
+		"AutoSplitVar":   {"Auto", "Split", "Var"},
+		"HTTPServerPort": {"HTTP", "Server", "Port"},
+		"ID":             {"ID"},
+		"lower":          {"lower"},
+	}
+	for in, expected := range cases {
+		if words := splitWords(in); !reflect.DeepEqual(words, expected) {
+			t.Fatalf("splitWords(%q) = %q, expected %q", in, words, expected)
+		}
+	}
+}
@@ -3,17 +3,29 @@
 package envdecode
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/x509"
 	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
-	"sort"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 // ErrInvalidTarget indicates that the target value passed to
@@ -26,17 +38,80 @@ var ErrNoTargetFieldsAreSet = errors.New("none of the target fields were set fro
 //
 // This variable can be assigned to another function of the user-programmer's
 // design, allowing for graceful recovery of the problem, such as loading
-// from a backup configuration file.
+// from a backup configuration file.  If any fields failed to decode, the
+// error is a DecodeErrors listing all of them, for handlers that report
+// problems in a structured form, such as JSONFailureFunc.
 var FailureFunc = func(err error) {
 	log.Fatalf("envdecode: an error was encountered while decoding: %v\n", err)
 }
 
+// FieldFailureFunc, if set, is called by MustDecode and MustStrictDecode
+// with each field that fails to decode, identifying the target, the
+// field and its variable, before FailureFunc is.  If it returns nil,
+// the field is left with the value it had, such as a fallback assigned
+// beforehand; otherwise the error it returns is reported to FailureFunc
+// along with those of the other fields that fail.
+var FieldFailureFunc func(err *DecodeError) error
+
 // Decoder is the interface implemented by an object that can decode an
 // environment variable string representation of itself.
 type Decoder interface {
 	Decode(string) error
 }
 
+// Encoder is the counterpart to Decoder, implemented by an object that
+// can produce the environment variable string representation of itself
+// that its Decode method accepts.  Export and Encode use it in
+// preference to encoding.TextMarshaler and fmt.Stringer.
+type Encoder interface {
+	EncodeEnv() (string, error)
+}
+
+var (
+	typeDecodersMu sync.RWMutex
+	typeDecoders   = map[reflect.Type]func(string) (interface{}, error){}
+)
+
+// RegisterTypeDecoder registers fn as the decoder for fields (and
+// slice elements) of type t, for types which cannot implement Decoder
+// themselves.  The value returned by fn must be assignable to t.  A
+// registered decoder takes precedence over Decoder, TextUnmarshaler
+// and the built-in conversions.  Registering a second decoder for the
+// same type replaces the first.
+func RegisterTypeDecoder(t reflect.Type, fn func(string) (interface{}, error)) {
+	if t == nil || fn == nil {
+		panic("envdecode: RegisterTypeDecoder called with nil type or function")
+	}
+
+	typeDecodersMu.Lock()
+	typeDecoders[t] = fn
+	typeDecodersMu.Unlock()
+}
+
+func typeDecoder(t reflect.Type) func(string) (interface{}, error) {
+	typeDecodersMu.RLock()
+	defer typeDecodersMu.RUnlock()
+	return typeDecoders[t]
+}
+
+func decodeWithTypeDecoder(f *reflect.Value, fn func(string) (interface{}, error), env string) error {
+	v, err := fn(env)
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		f.Set(reflect.Zero(f.Type()))
+		return nil
+	}
+	if !rv.Type().AssignableTo(f.Type()) {
+		return fmt.Errorf("envdecode: registered decoder returned %s, which cannot be assigned to %s", rv.Type(), f.Type())
+	}
+	f.Set(rv)
+	return nil
+}
+
 // Decode environment variables into the provided target.  The target
 // must be a non-nil pointer to a struct.  Fields in the struct must
 // be exported, and tagged with an "env" struct tag with a value
@@ -50,16 +125,202 @@ type Decoder interface {
 // will return an error on Decode if there is an error while parsing.
 // If everything must be strict, consider using StrictDecode instead.
 //
+// A default may fall back to other environment variables before a
+// literal value: ",default=$SHARED_HOST|$OTHER_HOST|localhost" uses
+// the first of SHARED_HOST and OTHER_HOST that is set, and otherwise
+// "localhost".  It may also fall back to the value of another field of
+// the same struct, declared earlier, with "field:Name", or
+// "field:Nested.Name" for a field of a nested struct:
+// ",default=field:ListenAddr" defaults to the value ListenAddr was
+// decoded to.  A literal default beginning with a dollar sign is
+// written with two: ",default=$$5" has the default "$5".
+//
+// A comma may be included in an option by escaping it with a
+// backslash, which must itself be escaped inside the Go struct tag:
+// `env:"HOSTS,default=a\\,b"` has the default "a,b".  "\\=" and "\\\\"
+// are likewise a literal equals sign and backslash.
+//
+// Fields holding credentials should be marked with ",secret" so that
+// their values are redacted by Export.
+//
+// Nested structs, usually embedded ones, may prefix the names of their
+// variables with ",prefix=PREFIX_" on the struct field, so that a
+// struct can be included more than once without its variables
+// colliding:
+//
+//	type Config struct {
+//		ReadTimeouts  Timeouts `env:",prefix=READ_"`
+//		WriteTimeouts Timeouts `env:",prefix=WRITE_"`
+//	}
+//
+// Prefixes of structs nested within each other are concatenated.
+//
+// A variable read by more than one field is usually a copy and paste
+// mistake, and is rejected by StrictDecode and ValidateStruct unless
+// every field reading it is marked ",shared".
+//
+// A description for documentation may be given with ",desc=text".
+// Because the tag is split on commas, long defaults and descriptions
+// may instead be placed in separate envDefault and envDesc tags, and
+// envRequired:"true" is equivalent to ",required":
+//
+//	Banner string `env:"BANNER" envDefault:"Hello, world" envDesc:"Greeting, shown at login"`
+//
+// *url.URL fields may be restricted to a set of schemes with
+// ",schemes=https;wss", and ",requireHost" rejects URLs without a host.
+// Values failing these checks always cause Decode to return an error.
+//
+// Appending ",loadfile" treats the value of the environment variable
+// as a path, and the contents of that file are decoded instead.
+// []byte fields receive the file contents verbatim.  Files larger than
+// 1 MiB are rejected unless a different limit is given in bytes with
+// ",maxsize=N".  With the WithPrivateSecretFiles option, files of
+// secret fields must not be accessible by other users.
+//
+// Values tagged ",encrypted" are decrypted by the function given with
+// WithDecryptor before they are decoded.  The value, including any
+// default, holds base64 encoded ciphertext, unless ",loadfile" is also
+// given, in which case the file holds the raw ciphertext.
+//
+// String fields, or slices of strings, tagged ",hostport" must hold
+// addresses such as "db.internal:5432" that net.SplitHostPort accepts,
+// with a numeric port or service name.  The HostPort type can be used
+// instead to decode the host and port into separate fields.
+//
+// The types of sync/atomic, such as atomic.Int64, atomic.Bool and
+// atomic.Value, are decoded like the types they hold and set with their
+// Store methods, so values can be updated in place while other
+// goroutines Load them.  An atomic.Value is decoded into the type of
+// the value it holds, or as a string if it is empty.
+//
+// Fields tagged ",immutable", such as data directories or listen
+// addresses that can't change while running, are decoded once: when
+// decoding again into a target in which they are already set, a value
+// differing from the current one is an error asking for a restart.
+//
+// Unexported fields are set through setter methods, so that structs
+// can enforce invariants: a field such as timeout is decoded into the
+// argument of a method SetTimeout on the struct's pointer, which may
+// return an error to reject the value.  Unexported fields without a
+// setter are ignored.
+//
+// A value may be composed from several variables with a format for
+// fmt.Sprintf, such as an address from a host and port:
+//
+//	Addr string `env:",compose=%s:%s,from=REDIS_HOST;REDIS_PORT"`
+//
+// The value counts as unset if none of the variables are set, so
+// defaults and ",required" apply as usual, while setting only some of
+// them is an error.  If the tag also names a variable, it is read
+// first, and the value composed only if it is unset.
+//
+// For more elaborate values, ",template=TEXT", or an envTemplate tag
+// for templates containing commas, renders a text/template in which
+// the env function returns the value of any variable:
+//
+//	URL string `envTemplate:"https://{{env \"HOST\"}}{{with env \"PORT\"}}:{{.}}{{end}}/"`
+//
+// As with ",compose", the value counts as unset if none of the
+// variables the template reads are set.
+//
+// A map[string]string field tagged ",prefixcapture=PREFIX" collects
+// every variable whose name starts with the prefix, keyed by the rest of
+// its name, for plugin systems passing arbitrary settings through:
+//
+//	Plugin map[string]string `env:",prefixcapture=PLUGIN_"`
+//
+// Similarly, a map[string]string field tagged ",remaining=PREFIX"
+// collects the variables starting with the prefix that no other field
+// of the target reads, keyed by their full names, so that misspelled or
+// unexpected variables can be logged or rejected.  Without a prefix,
+// ",remaining" uses that of the struct containing it, if any, or
+// collects every variable otherwise.
+//
+// With WithSources, only the sources implementing SourceLister are
+// searched by either.
+//
+// Values tagged ",trimquotes" have one pair of matching single or
+// double quotes around them removed, as left by some orchestration
+// tools, before they are decoded.
+//
+// Bools tagged ",lenient", or all bools with the WithLenientBools
+// option, also accept yes/no, y/n, on/off and enabled/disabled, in any
+// case.
+//
+// Numbers may be given with units using ",unit=NAME".  ",unit=rate"
+// decodes a floating point number of events per second from a number
+// per interval, such as "100/s", "6000/m" or "10/500ms", or "inf".
+// golang.org/x/time/rate.Limit fields are decoded as rates without the
+// option.
+// ",unit=bytes" decodes an integer number of bytes from a size such as
+// "512MiB", "10GB" or "64k"; KB, MB and so on are powers of 1000 while
+// KiB, MiB and K, M and so on are powers of 1024.
+// ",unit=percent" decodes a percentage between 0 and 100, such as
+// "85%" or "85", as a fraction like 0.85 into a floating point field;
+// ",unit=percent100" keeps it as 85.  A percentage outside that range
+// always fails Decode, even if the field isn't strict.
+//
+// Paths are resolved with ",type=path", which cleans the value.
+// ",expandhome" also replaces a leading ~ with the user's home
+// directory, ",mustexist" requires the path to exist, and ",dir" and
+// ",file" require it to be a directory or regular file respectively;
+// any of these imply ",type=path".  Paths failing these checks always
+// cause Decode to return an error:
+//
+//	DataDir string `env:"DATA_DIR,type=path,expandhome,dir"`
+//
+// Large values may be compressed: ",base64" decodes a base64 encoded
+// value, and ",gzip" then decompresses it, as in ",base64,gzip".  The
+// options combine with ",loadfile" and ",encrypted", in which case the
+// file is read first and decompression happens after decryption.
+// Decompressed values larger than 1 MiB, or the ",maxsize" limit, are
+// rejected.
+//
+// Fields of any type, typically structs, slices of structs or maps,
+// tagged ",json" are unmarshaled from a JSON document held in the
+// variable, and those tagged ",yaml" from a YAML document, using the
+// function given with WithYAML, such as gopkg.in/yaml.v3's Unmarshal:
+//
+//	Services []Service `env:"SERVICES,yaml"`
+//
+// The fields of structs decoded this way are not read from variables of
+// their own.  The options combine with ",loadfile", ",base64" and the
+// like.
+//
+// Slices of structs, or of pointers to structs, tagged ",csv" are
+// decoded from CSV records, one per line, or separated by semicolons
+// if the value is a single line.  Columns are
+// assigned to the exported fields of the struct in order, or with
+// ",csv=header" by the names in the first record, which are matched
+// with the fields' "csv" tags or, failing that, their names in any
+// case.  Cells are decoded like slice elements, and empty cells are
+// left as the zero value:
+//
+//	Users []User `env:"USERS,csv=header"` // USERS="name,admin;alice,true;bob,false"
+//
+// Maps are decoded from entries of the form key:value, separated by
+// semicolons like slices: "red:#f00;green:#0f0".  Keys and values may
+// be of any type a slice element may be.
+//
+// Slices and maps tagged ",escape" may contain the separator in their
+// elements by preceding it with a backslash, as in "a\;b;c", and a
+// backslash by doubling it; ",escape=^" uses another escape character.
+//
 // All primitive types are supported, including bool, floating point,
 // signed and unsigned integers, and string.  Boolean and numeric
 // types are decoded using the standard strconv Parse functions for
 // those types.  Structs and pointers to structs are decoded
-// recursively.  time.Duration is supported via the
-// time.ParseDuration() function and *url.URL is supported via the
-// url.Parse() function. Slices are supported for all above mentioned
-// primitive types. Semicolon is used as delimiter in environment variables.
+// recursively; a nil pointer is allocated only if one of its variables
+// is set, so an optional section left unconfigured stays nil even if
+// its fields have defaults.  Its required fields are still enforced.
+// time.Duration is supported via the time.ParseDuration() function and
+// *url.URL is supported via the url.Parse() function.  Private keys
+// (crypto.Signer, *rsa.PrivateKey and *ecdsa.PrivateKey) are parsed
+// from PEM, which may optionally be base64 encoded.  Slices are
+// supported for all above mentioned primitive types.  Semicolon is used
+// as delimiter in environment variables.
 func Decode(target interface{}) error {
-	nFields, err := decode(target, false)
+	nFields, err := newDecodeState(nil).decode(target, false)
 	if err != nil {
 		return err
 	}
@@ -73,137 +334,1818 @@ func Decode(target interface{}) error {
 	return nil
 }
 
-// StrictDecode is similar to Decode except all fields will have an implicit
-// ",strict" on all fields.
-func StrictDecode(target interface{}) error {
-	nFields, err := decode(target, true)
-	if err != nil {
-		return err
+// DecodeFields is like Decode, but only decodes the named top-level
+// fields of the target, including any structs nested within them.
+// Other fields are left untouched and their required variables are not
+// checked, so a command needing only part of a configuration isn't
+// prevented from running by variables it doesn't use.
+func DecodeFields(target interface{}, fields ...string) error {
+	t, err := structType(target)
+	if err != nil {
+		return err
+	}
+
+	d := newDecodeState(nil)
+	d.fields = map[string]bool{}
+	for _, name := range fields {
+		if _, ok := t.FieldByName(name); !ok {
+			return fmt.Errorf("envdecode: %s has no field %q", t, name)
+		}
+		d.fields[name] = true
+	}
+
+	nFields, err := d.decode(target, false)
+	if err != nil {
+		return err
+	}
+
+	if nFields == 0 {
+		return ErrNoTargetFieldsAreSet
+	}
+
+	return nil
+}
+
+// DecodeChanged re-decodes only the fields of an already decoded target
+// which read one of the changed variables, either directly or through a
+// chained default.  It is meant for reloading configuration when a
+// watched source reports which keys changed, without disturbing
+// unrelated fields.  A changed variable which is now unset reverts its
+// field to the default, if any, and otherwise leaves it untouched,
+// unless the field's ",unset" option says otherwise; see UnsetPolicy.
+// Unlike Decode, it is not an error if no fields are affected.
+func DecodeChanged(target interface{}, changed ...string) error {
+	d := newDecodeState(nil)
+	d.changed = map[string]bool{}
+	for _, name := range changed {
+		d.changed[name] = true
+	}
+
+	_, err := d.decode(target, false)
+	return err
+}
+
+// DecodeDynamic re-decodes only the fields of an already decoded target
+// tagged ",dynamic", updating them in place, for reloading simple knobs
+// such as rate limits or log levels without swapping the whole
+// configuration.  Dynamic fields must be of a sync/atomic type, so that
+// goroutines reading them with Load while the reload happens see either
+// the old value or the new one.  Other fields, and nil pointers to
+// optional sections, are left untouched.  Unlike Decode, it is not an
+// error if no fields are affected.
+func DecodeDynamic(target interface{}, opts ...Option) error {
+	d := newDecodeState(opts)
+	d.dynamicOnly = true
+	_, err := d.decode(target, false)
+	return err
+}
+
+// DecodeWithLookup is like Decode, but looks variables up with lookup,
+// such as os.LookupEnv or the lookup of a map in a test, instead of
+// reading the environment.
+func DecodeWithLookup(target interface{}, lookup func(name string) (string, bool)) error {
+	return DecodeWithOptions(target, WithSources(SourceFunc(lookup)))
+}
+
+// DecodeAll is like Decode, but decodes several targets, such as the
+// configuration of an application and of the libraries it uses, from a
+// single snapshot of the environment.  Every target sees the same value
+// for a variable, even if the environment changes part way through,
+// and each variable is looked up only once.
+func DecodeAll(targets ...interface{}) error {
+	d := newDecodeState(nil)
+	d.takeSnapshot()
+
+	for _, target := range targets {
+		nFields, err := d.decode(target, false)
+		if err != nil {
+			return err
+		}
+		if nFields == 0 {
+			return ErrNoTargetFieldsAreSet
+		}
+	}
+	return nil
+}
+
+// StrictDecode is similar to Decode except all fields will have an implicit
+// ",strict" on all fields.  It also fails if a variable is read by more
+// than one field not marked ",shared".
+func StrictDecode(target interface{}) error {
+	nFields, err := newDecodeState(nil).decode(target, true)
+	if err != nil {
+		return err
+	}
+
+	// if we didn't do anything - the user probably did something
+	// wrong like leave all fields unexported.
+	if nFields == 0 {
+		return ErrInvalidTarget
+	}
+
+	return nil
+}
+
+// decodeState holds the configuration of a single decode operation.
+type decodeState struct {
+	options
+
+	// fields restricts decoding to the named top-level fields, if set.
+	fields map[string]bool
+
+	// dynamicOnly restricts decoding to fields tagged ",dynamic".
+	dynamicOnly bool
+
+	// changed restricts decoding to fields reading the named
+	// variables, if set.
+	changed map[string]bool
+
+	// path holds the names of the struct fields leading to the struct
+	// being decoded.
+	path []string
+
+	// namePrefix is prepended to the names in "env" tags of the
+	// struct being decoded, from the "prefix" options of the struct
+	// fields leading to it.
+	namePrefix string
+
+	// stats, if set, collects statistics for WithTrace.
+	stats *DecodeStats
+
+	// snapshot, if set, holds the results of lookups already made,
+	// so that every lookup of a variable gives the same value.
+	snapshot map[string]lookupResult
+
+	// root is the type of the target and rootPrefix the name prefix
+	// it is decoded with.  consumed and captured hold the variables
+	// and prefixes its fields read, for fields tagged ",remaining".
+	root       reflect.Type
+	rootPrefix string
+	consumed   map[string]bool
+	captured   []string
+
+	// current is the struct being decoded, and decoded holds the
+	// values of the fields set so far by their paths, for defaults
+	// referring to other fields.
+	current reflect.Value
+	decoded map[string]reflect.Value
+
+	// recorded, if set, collects the values of the variables looked
+	// up, for CaptureSnapshot.  Those looked up while decoding a secret
+	// field, as flagged by inSecret, are also collected in secretNames,
+	// including the variables of its default chain or template.
+	recorded    map[string]string
+	secretNames map[string]bool
+	inSecret    bool
+
+	// present counts the fields decoded so far whose variables were
+	// set, to tell whether a nil pointer struct should be allocated.
+	present int
+
+	// dryRun decodes values into copies of the fields, leaving the
+	// target untouched.
+	dryRun bool
+
+	// onField, if set, is called after each tagged field is decoded.
+	onField func(path string, opts tagOptions, r fieldResult)
+
+	// missing lists the required variables found unset, and defaulted
+	// counts the fields given their defaults, for Metrics.
+	// collectMissing carries on past missing variables rather than
+	// failing.
+	collectMissing bool
+	missing        []string
+	defaulted      int
+
+	// failure is the error of the first missing variable when Metrics
+	// are recorded, decoding carrying on to count the rest.
+	failure error
+
+	// collectErrors records the errors of fields in errors rather
+	// than failing.
+	collectErrors bool
+	errors        DecodeErrors
+
+	// onError, if set, is called with the error of each field.  If it
+	// returns nil, decoding continues.
+	onError func(*DecodeError) error
+
+	// target is the name of the type of the struct being decoded.
+	target string
+
+	// field is the path of the field being decoded, for warnings.
+	field string
+
+	// visiting holds the structs being decoded, and visitingTypes
+	// their types, to detect cycles.
+	visiting      map[visit]bool
+	visitingTypes map[reflect.Type]int
+}
+
+// visit identifies a struct being decoded.  The type distinguishes a
+// struct from its first field.
+type visit struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// defaultMaxDepth is how deeply structs may be nested unless
+// WithMaxDepth says otherwise.
+const defaultMaxDepth = 64
+
+func newDecodeState(opts []Option) *decodeState {
+	d := &decodeState{}
+	for _, o := range opts {
+		o(&d.options)
+	}
+	if d.naming == nil {
+		d.naming = ScreamingSnakeCase
+	}
+	if d.maxDepth == 0 {
+		d.maxDepth = defaultMaxDepth
+	}
+	if d.runSecrets {
+		if d.sources == nil {
+			d.sources = []Source{Environment}
+		}
+		d.sources = append(d.sources, DirSource(defaultSecretsDir))
+	}
+	if d.snapshotEnv {
+		d.takeSnapshot()
+	}
+	return d
+}
+
+// typeDecoder returns the decoder for t, preferring one given as an
+// Option over one registered with RegisterTypeDecoder.
+func (d *decodeState) typeDecoder(t reflect.Type) func(string) (interface{}, error) {
+	if fn := d.decoders[t]; fn != nil {
+		return fn
+	}
+	return typeDecoder(t)
+}
+
+// getenv returns the value of the variable name from the first source
+// where it is set to a non-empty value, or an empty string if there is
+// none.  Without sources, only the environment is consulted.
+func (d *decodeState) getenv(name string) (string, error) {
+	v, _, err := d.lookup(name)
+	return v, err
+}
+
+// lookupResult is a value and the description of its source.
+type lookupResult struct {
+	value, origin string
+}
+
+// takeSnapshot starts recording lookups in d.snapshot.  The environment
+// is copied up front when it is the only source, as it can't otherwise
+// be read atomically.
+func (d *decodeState) takeSnapshot() {
+	d.snapshot = map[string]lookupResult{}
+	if d.sources != nil {
+		return
+	}
+	for _, kv := range os.Environ() {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			d.snapshot[kv[:i]] = lookupResult{kv[i+1:], sourceEnv}
+		}
+	}
+}
+
+// lookup is like getenv, but also describes the source the value came
+// from, as reported in ConfigInfo.Source.
+func (d *decodeState) lookup(name string) (value, origin string, err error) {
+	if r, ok := d.snapshot[name]; ok || (d.snapshot != nil && d.sources == nil) {
+		value, origin = r.value, r.origin
+	} else if value, origin, err = d.lookupSources(name); err == nil && d.snapshot != nil {
+		d.snapshot[name] = lookupResult{value, origin}
+	}
+	if d.recorded != nil && value != "" {
+		d.recorded[name] = value
+		if d.inSecret {
+			d.secretNames[name] = true
+		}
+	}
+	return value, origin, err
+}
+
+// names returns the names of the variables in the sources that can
+// list them, or in the environment if there are none, sorted and
+// without duplicates.
+func (d *decodeState) names() ([]string, error) {
+	var names []string
+	switch {
+	case d.sources == nil && d.snapshot != nil:
+		for name := range d.snapshot {
+			names = append(names, name)
+		}
+	case d.sources == nil:
+		names = environNames(os.Environ())
+	}
+	for _, src := range d.sources {
+		if sl, ok := src.(SourceLister); ok {
+			n, err := sl.Names()
+			if err != nil {
+				return nil, fmt.Errorf("envdecode: listing variables: %v", err)
+			}
+			names = append(names, n...)
+		}
+	}
+	return uniqueStrings(names), nil
+}
+
+// lookupSources looks name up in the sources, or the environment if
+// there are none.
+func (d *decodeState) lookupSources(name string) (string, string, error) {
+	if d.sources == nil {
+		if d.stats != nil {
+			d.stats.Lookups++
+		}
+		return os.Getenv(name), sourceEnv, nil
+	}
+	for _, src := range d.sources {
+		if d.stats != nil {
+			d.stats.Lookups++
+		}
+		var v, origin string
+		var err error
+		if ds, ok := src.(*dirSource); ok {
+			var path string
+			if v, path, err = ds.lookup(name, &d.options); path != "" {
+				origin = "file:" + path
+			}
+		} else {
+			v, _, err = src.Lookup(name)
+			origin = describeSource(src, name)
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("envdecode: looking up \"%s\": %v", name, err)
+		}
+		if v != "" {
+			return v, origin, nil
+		}
+	}
+	return "", "", nil
+}
+
+// expandWindows replaces %NAME% in s with the value of the variable
+// NAME, and %% with %, leaving references to unset variables alone.
+func (d *decodeState) expandWindows(s string) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '%')
+		j := strings.IndexByte(s[i+1:], '%')
+		if i < 0 || j < 0 {
+			break
+		}
+		name := s[i+1 : i+1+j]
+		b.WriteString(s[:i])
+
+		if name == "" {
+			b.WriteByte('%')
+			s = s[i+2:]
+			continue
+		}
+		v, err := d.getenv(name)
+		if err != nil {
+			return "", err
+		}
+		if v == "" {
+			// Keep the closing % to start the next reference, as
+			// cmd.exe does.
+			b.WriteString(s[i : i+1+j])
+			s = s[i+1+j:]
+			continue
+		}
+		b.WriteString(v)
+		s = s[i+2+j:]
+	}
+	b.WriteString(s)
+	return b.String(), nil
+}
+
+// resolveDefault evaluates a default value.  A default beginning with
+// "$" or "field:" is a "|"-separated chain: each "$NAME" element is
+// replaced by the value of that variable if it is set, each
+// "field:Name" element by the value of that field of the struct being
+// decoded if it isn't empty, and the first element that is neither is
+// used literally, along with the rest of the chain.  A literal beginning
+// with "$$" stands for one beginning with "$".
+func (d *decodeState) resolveDefault(def string) (string, error) {
+	if !isDefaultReference(def) {
+		return unescapeDefault(def), nil
+	}
+
+	chain := strings.Split(def, "|")
+	for i, link := range chain {
+		var v string
+		var err error
+		switch {
+		case !isDefaultReference(link):
+			return unescapeDefault(strings.Join(chain[i:], "|")), nil
+		case strings.HasPrefix(link, "$"):
+			v, err = d.getenv(link[1:])
+		default:
+			v, err = d.fieldValue(link[len(fieldReference):])
+		}
+		if v != "" || err != nil {
+			return v, err
+		}
+	}
+	return "", nil
+}
+
+// fieldReference starts the elements of default chains referring to
+// other fields.
+const fieldReference = "field:"
+
+// isDefaultReference reports whether the element of a default chain,
+// or the chain itself, starts with a reference to a variable or field.
+// An escaped "$$" is not a reference.
+func isDefaultReference(link string) bool {
+	if strings.HasPrefix(link, "$$") {
+		return false
+	}
+	return strings.HasPrefix(link, "$") || strings.HasPrefix(link, fieldReference)
+}
+
+// unescapeDefault returns the literal value of the default def,
+// replacing a leading "$$" with "$".
+func unescapeDefault(def string) string {
+	if strings.HasPrefix(def, "$$") {
+		return def[1:]
+	}
+	return def
+}
+
+// fieldValue returns the formatted value of the field at path, such as
+// "Addr" or "Server.Addr", in the struct being decoded, as decoded so
+// far.
+func (d *decodeState) fieldValue(path string) (string, error) {
+	full := d.fieldPath(path)
+	if v, ok := d.decoded[full]; ok {
+		return formatValue(v)
+	}
+
+	v := d.current
+	for _, name := range strings.Split(path, ".") {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return "", nil
+			}
+			v = v.Elem()
+		}
+		if v.Kind() == reflect.Struct {
+			if sf, ok := v.Type().FieldByName(name); ok && sf.PkgPath == "" {
+				v = v.FieldByIndex(sf.Index)
+				continue
+			}
+		}
+		return "", fmt.Errorf("envdecode: default refers to unknown field %s", full)
+	}
+	return formatValue(v)
+}
+
+func (d *decodeState) decode(target interface{}, strict bool) (n int, err error) {
+	if d.noPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				n, err = 0, &DecodeError{Target: d.target, Field: d.field, Err: fmt.Errorf("envdecode: panic while decoding: %v", r)}
+			}
+		}()
+	}
+
+	s := reflect.ValueOf(target)
+	if s.Kind() != reflect.Ptr || s.IsNil() {
+		return 0, ErrInvalidTarget
+	}
+
+	s = s.Elem()
+	if s.Kind() != reflect.Struct {
+		return 0, ErrInvalidTarget
+	}
+
+	if strict {
+		if errs := duplicates(d.envFields(s.Type())); len(errs) > 0 {
+			return 0, errs[0]
+		}
+	}
+
+	d.target = s.Type().String()
+	d.root, d.rootPrefix = s.Type(), d.namePrefix
+	d.consumed, d.captured = nil, nil
+	d.decoded, d.present = nil, 0
+	d.missing, d.defaulted, d.failure = nil, 0, nil
+
+	var endTrace func(error)
+	if d.trace != nil {
+		endTrace = d.startTrace(target)
+	}
+
+	n, err = d.decodeStruct(s, d.envconfigPrefix, strict)
+	if err == nil && d.failure != nil {
+		n, err = 0, d.failure
+	}
+	if endTrace != nil {
+		endTrace(err)
+	}
+	if d.metrics != nil && !d.dryRun {
+		if err == nil && len(d.errors) > 0 {
+			d.metrics.record(d, d.errors)
+		} else {
+			d.metrics.record(d, err)
+		}
+	}
+	return n, err
+}
+
+// fieldTag returns the options for a struct field, falling back to
+// envconfig conventions for fields without an "env" tag when that
+// compatibility mode is enabled.  Names from "env" tags are given the
+// prefixes of the structs containing them, and in the automatic prefix
+// mode the automatic prefix as well.  A templated name that can't be
+// expanded is returned as an error, failing the field, as well as
+// recorded as a problem for ValidateStruct.
+func (d *decodeState) fieldTag(sf reflect.StructField, prefix string) (tagOptions, bool, error) {
+	var nameErr error
+	opts, ok := fieldTag(sf)
+	switch {
+	case ok:
+		fullName := func(name string) string {
+			if name == "" {
+				return ""
+			}
+			name, err := d.expandName(name)
+			if err != nil {
+				opts.problems = append(opts.problems, err.Error())
+				if nameErr == nil {
+					nameErr = fmt.Errorf("envdecode: %v", err)
+				}
+			}
+			name = d.namePrefix + name
+			if d.autoPrefix && prefix != "" {
+				name = d.naming([]string{prefix, name})
+			}
+			return name
+		}
+		opts.name = fullName(opts.name)
+		if opts.capture != "" {
+			opts.capture = d.namePrefix + opts.capture
+		}
+		if opts.remaining {
+			opts.remainingPrefix = d.namePrefix + opts.remainingPrefix
+		}
+		if opts.from != nil {
+			from := make([]string, len(opts.from))
+			for i, name := range opts.from {
+				from[i] = fullName(name)
+			}
+			opts.from = from
+		}
+	case d.envconfig:
+		opts, ok = envconfigTag(sf, prefix)
+	}
+	if ok && d.sliceSeparator != "" && !opts.csv {
+		opts.separator = d.sliceSeparator
+	}
+	return opts, ok, nameErr
+}
+
+// nestedPrefix returns the prefix for variables of the nested struct
+// field sf.  Only the envconfig compatibility and automatic prefix
+// modes use prefixes.
+func (d *decodeState) nestedPrefix(sf reflect.StructField, prefix string) string {
+	switch {
+	case d.envconfig:
+		return envconfigPrefix(sf, prefix)
+	case d.autoPrefix && !sf.Anonymous:
+		words := splitWords(sf.Name)
+		if prefix != "" {
+			words = append([]string{prefix}, words...)
+		}
+		return d.naming(words)
+	}
+	return prefix
+}
+
+func (d *decodeState) decodeStruct(s reflect.Value, prefix string, strict bool) (int, error) {
+	t := s.Type()
+	if len(d.path) > d.maxDepth {
+		return 0, &DecodeError{Target: d.target, Field: strings.Join(d.path, "."), Err: fmt.Errorf("envdecode: structs are nested more than %d deep", d.maxDepth)}
+	}
+	if d.visiting == nil {
+		d.visiting = map[visit]bool{}
+		d.visitingTypes = map[reflect.Type]int{}
+	}
+	v := visit{s.Addr().Pointer(), t}
+	if d.visiting[v] {
+		return 0, &DecodeError{Target: d.target, Field: strings.Join(d.path, "."), Err: fmt.Errorf("envdecode: pointer cycle through %s", t)}
+	}
+	d.visiting[v] = true
+	d.visitingTypes[t]++
+	current := d.current
+	d.current = s
+	defer func() {
+		delete(d.visiting, v)
+		d.visitingTypes[t]--
+		d.current = current
+	}()
+
+	setFieldCount := 0
+	for i := 0; i < s.NumField(); i++ {
+		if len(d.path) == 0 && d.fields != nil && !d.fields[t.Field(i).Name] {
+			continue
+		}
+
+		// Localize the umbrella `strict` value to the specific field.
+		strict := strict
+
+		f := s.Field(i)
+
+		// ptr is a nil pointer to a struct, which is set to point to
+		// f if any of f's variables are set.  Defaults alone leave it
+		// nil, so that an unconfigured section can be told apart.
+		var ptr reflect.Value
+
+		switch f.Kind() {
+		case reflect.Ptr:
+			if f.Type().Elem().Kind() != reflect.Struct || isPrivateKeyType(f.Type()) || d.typeDecoder(f.Type()) != nil || isDocument(t.Field(i)) {
+				break
+			}
+
+			if !f.IsNil() {
+				f = f.Elem()
+			} else if isLeafType(f.Type()) || !f.CanSet() || d.visitingTypes[f.Type().Elem()] > 0 {
+				// A nil pointer to a type being decoded is left
+				// alone, as it would be allocated forever.
+				break
+			} else {
+				ptr = f
+				f = reflect.New(f.Type().Elem()).Elem()
+			}
+			fallthrough
+
+		case reflect.Struct:
+			if !f.Addr().CanInterface() {
+				continue
+			}
+			if isDocument(t.Field(i)) {
+				break
+			}
+			if d.envconfig && envconfigIgnored(t.Field(i)) {
+				continue
+			}
+
+			ss := f.Addr().Interface()
+			_, custom := ss.(Decoder)
+			if custom || d.typeDecoder(f.Type()) != nil || isAtomicType(f.Type()) {
+				break
+			}
+
+			namePrefix, present := d.namePrefix, d.present
+			d.path = append(d.path, t.Field(i).Name)
+			d.namePrefix += structPrefix(t.Field(i))
+			n, err := d.decodeStruct(f, d.nestedPrefix(t.Field(i), prefix), strict)
+			d.path = d.path[:len(d.path)-1]
+			d.namePrefix = namePrefix
+			if err != nil {
+				return 0, err
+			}
+			setFieldCount += n
+			if ptr.IsValid() {
+				if d.present > present && !d.dryRun && !d.dynamicOnly {
+					ptr.Set(f.Addr())
+				}
+				continue
+			}
+
+			if _, ok := fieldTag(t.Field(i)); !ok {
+				continue
+			}
+		}
+
+		// set is the setter method of an unexported field, which is
+		// passed the decoded value in place of the field.
+		set, hasSetter := setter(t, t.Field(i))
+		if hasSetter {
+			f = reflect.New(set.Type.In(1)).Elem()
+		} else if !f.CanSet() {
+			continue
+		}
+
+		opts, ok, nameErr := d.fieldTag(t.Field(i), prefix)
+		if !ok {
+			continue
+		}
+		opts = opts.forProfile(d.profile)
+		if d.changed != nil && !opts.readsAny(d.changed) {
+			continue
+		}
+		if d.dynamicOnly && !opts.dynamic {
+			continue
+		}
+		if d.dynamicOnly && !isAtomicType(derefType(f.Type())) {
+			return 0, &DecodeError{Target: d.target, Field: d.fieldPath(t.Field(i).Name), EnvVar: opts.name, Err: fmt.Errorf("envdecode: dynamic field of type %s is not of a sync/atomic type", f.Type())}
+		}
+
+		if !strict {
+			strict = opts.strict
+		}
+
+		d.field = d.fieldPath(t.Field(i).Name)
+		d.inSecret = opts.secret || d.redacts(opts.name)
+		var r fieldResult
+		var err error
+		switch {
+		case nameErr != nil:
+			err = nameErr
+		case opts.capture != "" || opts.remaining:
+			r, err = d.decodeCapture(f, opts)
+		case opts.immutable && !d.dryRun && !isZeroValue(f):
+			r, err = d.decodeImmutable(f, opts, strict)
+		default:
+			r, err = d.decodeField(f, opts, strict)
+		}
+		if err == nil && hasSetter && r.set && !d.dryRun {
+			err = callSetter(s, set, f)
+		}
+		if err == nil && r.set && d.postprocessor != nil {
+			err = d.postprocessor(d.fieldInfo(f, opts, r.source), r.value.Interface())
+		}
+		d.field, d.inSecret = "", false
+		if err != nil {
+			de := &DecodeError{Target: d.target, Field: d.fieldPath(t.Field(i).Name), EnvVar: opts.name, Err: err}
+			var failure error = de
+			if d.onError != nil {
+				if failure = d.onError(de); failure == nil {
+					continue
+				}
+			}
+			var fde *DecodeError
+			if d.collectErrors && errors.As(failure, &fde) {
+				d.errors = append(d.errors, fde)
+				continue
+			}
+			// Metrics count every missing variable, so decoding
+			// carries on and fails with the first at the end.
+			if d.metrics != nil && !d.dryRun && isMissingError(err) {
+				if d.failure == nil {
+					d.failure = failure
+				}
+				continue
+			}
+			return 0, failure
+		}
+		if r.set {
+			setFieldCount++
+			if d.decoded == nil {
+				d.decoded = map[string]reflect.Value{}
+			}
+			d.decoded[d.fieldPath(t.Field(i).Name)] = r.value
+		}
+		if r.fromEnv {
+			d.present++
+		}
+
+		if d.onField != nil && opts.name != "" {
+			d.onField(d.fieldPath(t.Field(i).Name), opts, r)
+		}
+	}
+
+	return setFieldCount, nil
+}
+
+// decodeImmutable decodes the field f tagged ",immutable", which
+// already holds a value, failing if the value would change.
+func (d *decodeState) decodeImmutable(f reflect.Value, opts tagOptions, strict bool) (fieldResult, error) {
+	d.dryRun = true
+	r, err := d.decodeField(f, opts, strict)
+	d.dryRun = false
+	if err != nil || !r.set {
+		return r, err
+	}
+	if !reflect.DeepEqual(r.value.Interface(), f.Interface()) {
+		return r, errors.New("envdecode: immutable value has changed; restart to apply it")
+	}
+	r.value = f
+	return r, nil
+}
+
+// decodeCapture sets the map[string]string field f, tagged
+// ",prefixcapture=PREFIX", to the variables whose names start with the
+// prefix, keyed by their names without it, or, tagged ",remaining", to
+// those no other field of the target reads, keyed by their full names.
+// The field is left alone if there are none.
+func (d *decodeState) decodeCapture(f reflect.Value, opts tagOptions) (fieldResult, error) {
+	r := fieldResult{value: f}
+	if f.Type() != stringMapType {
+		return r, fmt.Errorf("envdecode: %s field must be a map[string]string, not %s", opts.captureOption(), f.Type())
+	}
+
+	names, err := d.names()
+	if err != nil {
+		return r, err
+	}
+	prefix, strip := opts.capture, true
+	if opts.remaining {
+		prefix, strip = opts.remainingPrefix, false
+	}
+	m := map[string]string{}
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) || (strip && name == prefix) || (opts.remaining && d.consumes(name)) {
+			continue
+		}
+		key := name
+		if strip {
+			key = name[len(prefix):]
+		}
+		v, origin, err := d.lookup(name)
+		if err != nil {
+			return r, err
+		}
+		if v != "" {
+			m[key] = v
+			r.source = origin
+		}
+	}
+	if len(m) == 0 {
+		return r, nil
+	}
+
+	r.value = reflect.ValueOf(m)
+	if !d.dryRun {
+		f.Set(r.value)
+	}
+	r.set, r.fromEnv = true, true
+	return r, nil
+}
+
+// stringMapType is the type of fields tagged ",prefixcapture" or
+// ",remaining".
+var stringMapType = reflect.TypeOf(map[string]string(nil))
+
+// captureOption returns the name of the option making opts capture
+// variables, for messages.
+func (opts tagOptions) captureOption() string {
+	if opts.remaining {
+		return "remaining"
+	}
+	return "prefixcapture"
+}
+
+// consumes reports whether a field of the target being decoded, other
+// than those tagged ",remaining", reads the variable name.
+func (d *decodeState) consumes(name string) bool {
+	if d.consumed == nil {
+		namePrefix := d.namePrefix
+		d.namePrefix = d.rootPrefix
+		fields := d.envFields(d.root)
+		d.namePrefix = namePrefix
+
+		d.consumed = map[string]bool{}
+		for _, f := range fields {
+			for _, n := range append([]string{f.opts.name, f.opts.altName}, f.opts.from...) {
+				d.consumed[n] = true
+			}
+			for _, old := range d.renamed[f.opts.name] {
+				d.consumed[old] = true
+			}
+			if f.opts.capture != "" {
+				d.captured = append(d.captured, f.opts.capture)
+			}
+		}
+	}
+	if d.consumed[name] {
+		return true
+	}
+	for _, prefix := range d.captured {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDocument reports whether sf is tagged to be unmarshaled from a JSON
+// or YAML document rather than decoded field by field.
+func isDocument(sf reflect.StructField) bool {
+	opts, ok := fieldTag(sf)
+	return ok && (opts.json || opts.yaml)
+}
+
+// fieldPath returns the dotted path of the named field of the struct
+// currently being decoded.
+func (d *decodeState) fieldPath(name string) string {
+	return strings.Join(append(d.path[:len(d.path):len(d.path)], name), ".")
+}
+
+// fieldResult describes the outcome of decoding a single field.
+type fieldResult struct {
+	// value holds the field's value after decoding.  It is a copy of
+	// the field when doing a dry run.
+	value reflect.Value
+
+	// set is true if the field was assigned, either from the
+	// environment or a default.
+	set bool
+
+	// fromEnv is true if the variable was present in the environment.
+	fromEnv bool
+
+	// source describes where the value came from, such as "env",
+	// "default" or "file:/run/secrets/db_password".
+	source string
+}
+
+// decodeField looks up the variable for the field f and decodes it.
+func (d *decodeState) decodeField(f reflect.Value, opts tagOptions, strict bool) (fieldResult, error) {
+	r := fieldResult{value: f}
+
+	var env, source string
+	var err error
+	if opts.name != "" {
+		if env, source, err = d.lookup(opts.name); err != nil {
+			return r, err
+		}
+	}
+	if env == "" && opts.compose != "" {
+		if env, source, err = d.compose(opts); err != nil {
+			return r, err
+		}
+	}
+	if env == "" && opts.template != "" {
+		if env, source, err = d.renderTemplate(opts); err != nil {
+			return r, err
+		}
+	}
+	if env == "" && opts.altName != "" {
+		if env, source, err = d.lookup(opts.altName); err != nil {
+			return r, err
+		}
+		if env != "" {
+			d.warn(opts, "%s is not set; using %s instead", opts.name, opts.altName)
+		}
+	}
+	if env == "" && d.renamed != nil {
+		var old string
+		if env, source, old, err = d.lookupRenamed(opts); err != nil {
+			return r, err
+		}
+		if env != "" && d.strictRenames {
+			return r, fmt.Errorf("envdecode: \"%s\" is deprecated; use \"%s\" instead", old, opts.name)
+		}
+		if env != "" {
+			d.warn(opts, "%s is deprecated; use %s instead", old, opts.name)
+		}
+	}
+	if d.fileDescriptors && strings.HasPrefix(env, "fd:") {
+		v, err := d.readDescriptor(env)
+		if err != nil {
+			return r, fmt.Errorf("envdecode: reading \"%s\" from %s: %v", opts.name, env, err)
+		}
+		env, source = v, env
+	}
+	if env == "" && opts.required && !opts.hasDefault && d.prompt && !d.dryRun {
+		if env, err = d.promptFor(opts); err != nil {
+			return r, err
+		}
+		source = "prompt"
+	}
+	r.fromEnv = env != ""
+
+	if opts.required && opts.hasDefault {
+		if d.noPanics {
+			return r, errors.New(`envdecode: "default" and "required" may not be specified in the same annotation`)
+		}
+		panic(`envdecode: "default" and "required" may not be specified in the same annotation`)
+	}
+	if env == "" && opts.required {
+		missing := []string{opts.name}
+		if opts.name == "" && opts.from != nil {
+			missing = opts.from
+		}
+		if missing[0] == "" {
+			// A template has no variables to report.
+			return r, errors.New("none of the variables read by the template are set")
+		}
+		d.missing = append(d.missing, missing...)
+		if d.collectMissing {
+			return r, nil
+		}
+		return r, missingError{fmt.Errorf("the environment variable \"%s\" is missing", missing[0])}
+	}
+	policy := d.unsetPolicy(opts)
+	if env == "" && policy == UnsetRetain && !isZeroValue(f) {
+		d.warn(opts, "%s is unset; keeping the previous value", opts.name)
+		return r, nil
+	}
+	if env == "" {
+		if env, err = d.resolveDefault(opts.defaultValue); err != nil {
+			return r, err
+		}
+		source = sourceDefault
+	}
+	if !r.fromEnv && env != "" {
+		d.defaulted++
+	}
+	if d.stats != nil {
+		d.stats.Fields++
+		if r.fromEnv {
+			d.stats.Set++
+		} else if env != "" {
+			d.stats.Defaults++
+		}
+	}
+	if env == "" {
+		if policy == UnsetZero && !isZeroValue(f) {
+			d.warn(opts, "%s is unset; resetting to the zero value", opts.name)
+			r.set = true
+			if d.dryRun {
+				r.value = reflect.New(f.Type()).Elem()
+			} else {
+				setZero(f)
+			}
+		}
+		return r, nil
+	}
+	if opts.trimQuotes {
+		env = trimQuotes(env)
+	}
+	if d.windowsExpansion {
+		if env, err = d.expandWindows(env); err != nil {
+			return r, err
+		}
+	}
+	if d.preprocessor != nil {
+		if env, err = d.preprocessor(d.fieldInfo(f, opts, source), env); err != nil {
+			return r, fmt.Errorf("envdecode: preprocessing \"%s\": %v", opts.name, err)
+		}
+	}
+
+	r.set = true
+	r.source = source
+
+	if opts.secret && source == sourceDefault {
+		d.warn(opts, "secret is using its default value")
+	}
+	if f.Kind() == reflect.Slice && !opts.transformed() && hasEmptyElements(opts.split(env)) {
+		d.warn(opts, "empty elements of %q are ignored", env)
+	}
+
+	if d.dryRun {
+		tmp := reflect.New(f.Type()).Elem()
+		tmp.Set(f)
+		f = tmp
+		r.value = f
+	}
+
+	// raw holds binary contents, from a file or decryption, in place
+	// of env.
+	var raw []byte
+	if opts.loadFile {
+		if raw, err = readFile(env, opts.maxSize, opts.secret && d.privateSecretFiles); err != nil {
+			return r, fmt.Errorf("envdecode: loading file for \"%s\": %v", opts.name, err)
+		}
+		r.source = "file:" + env
+	}
+	if opts.base64 {
+		in := env
+		if raw != nil {
+			in = string(raw)
+		}
+		if raw, err = base64.StdEncoding.DecodeString(strings.TrimSpace(in)); err != nil {
+			return r, fmt.Errorf("envdecode: decoding base64 for \"%s\": %v", opts.name, err)
+		}
+	}
+	if opts.encrypted {
+		if raw, err = d.decrypt(env, raw); err != nil {
+			return r, fmt.Errorf("envdecode: decrypting \"%s\": %v", opts.name, err)
+		}
+	}
+	if opts.gzip {
+		if raw == nil {
+			raw = []byte(env)
+		}
+		if raw, err = gunzip(raw, opts.maxSize); err != nil {
+			return r, fmt.Errorf("envdecode: decompressing \"%s\": %v", opts.name, err)
+		}
+	}
+	if opts.transforms != nil {
+		in := env
+		if raw != nil {
+			in = string(raw)
+		}
+		out, err := applyTransforms(in, opts)
+		if err != nil {
+			return r, fmt.Errorf("envdecode: transforming \"%s\": %v", opts.name, err)
+		}
+		if raw != nil {
+			raw = []byte(out)
+		} else {
+			env = out
+		}
+	}
+	if opts.json || opts.yaml {
+		if raw == nil {
+			raw = []byte(env)
+		}
+		return r, d.unmarshal(f, raw, opts)
+	}
+	if opts.csv {
+		if raw != nil {
+			env = string(raw)
+		}
+		if err := d.decodeCSV(f, env, opts); err != nil {
+			return r, fmt.Errorf("envdecode: decoding CSV for \"%s\": %v", opts.name, err)
+		}
+		return r, nil
+	}
+	if raw != nil {
+		if f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.Uint8 {
+			f.SetBytes(raw)
+			return r, nil
+		}
+		env = string(raw)
+	}
+
+	if opts.isPath() {
+		if env, err = opts.resolvePath(env); err != nil {
+			return r, fmt.Errorf("envdecode: invalid value for \"%s\": %v", opts.name, err)
+		}
+	}
+
+	return r, d.decodeValue(f, env, opts, strict)
+}
+
+// compose formats the value of a field tagged ",compose" from the
+// variables named by its "from" option, returning "" if none of them
+// are set.  The value is described as "compose".
+func (d *decodeState) compose(opts tagOptions) (string, string, error) {
+	var values []interface{}
+	var missing []string
+	for _, name := range opts.from {
+		v, _, err := d.lookup(name)
+		if err != nil {
+			return "", "", err
+		}
+		if v == "" {
+			missing = append(missing, name)
+		}
+		values = append(values, v)
+	}
+
+	switch len(missing) {
+	case 0:
+		return fmt.Sprintf(opts.compose, values...), "compose", nil
+	case len(opts.from):
+		return "", "", nil
+	}
+	return "", "", fmt.Errorf("the environment variable \"%s\" is missing", missing[0])
+}
+
+// unmarshal decodes the JSON or YAML document data into the
+// addressable value f.
+func (d *decodeState) unmarshal(f reflect.Value, data []byte, opts tagOptions) error {
+	if opts.json {
+		if err := json.Unmarshal(data, f.Addr().Interface()); err != nil {
+			return fmt.Errorf("envdecode: decoding JSON for \"%s\": %v", opts.name, err)
+		}
+		return nil
+	}
+	if d.yamlUnmarshal == nil {
+		return fmt.Errorf("envdecode: decoding YAML for \"%s\": no unmarshal function given with WithYAML", opts.name)
+	}
+	if err := d.yamlUnmarshal(data, f.Addr().Interface()); err != nil {
+		return fmt.Errorf("envdecode: decoding YAML for \"%s\": %v", opts.name, err)
+	}
+	return nil
+}
+
+// decodeValue converts env and stores it in the addressable value f.
+// Conversion errors of slices and primitive types are only reported
+// when strict.
+func (d *decodeState) decodeValue(f reflect.Value, env string, opts tagOptions, strict bool) error {
+	if t := f.Type(); t.Kind() == reflect.Ptr && isAtomicType(t.Elem()) {
+		if f.IsNil() {
+			f.Set(reflect.New(t.Elem()))
+		}
+		f = f.Elem()
+	}
+	if isAtomicType(f.Type()) {
+		return d.decodeAtomic(f, env, opts, strict)
+	}
+
+	if t := f.Type(); t.Kind() == reflect.Ptr && d.typeDecoder(t) == nil && (t.Implements(decoderType) || t.Implements(textUnmarshalerType)) {
+		if f.IsNil() {
+			f.Set(reflect.New(t.Elem()))
+		}
+		f = f.Elem()
+	}
+
+	unmarshaler, implementsUnmarshaler := f.Addr().Interface().(encoding.TextUnmarshaler)
+	decoder, implmentsDecoder := f.Addr().Interface().(Decoder)
+	if fn := d.typeDecoder(f.Type()); fn != nil {
+		if err := decodeWithTypeDecoder(&f, fn, env); err != nil {
+			return err
+		}
+	} else if implmentsDecoder {
+		if err := decoder.Decode(env); err != nil {
+			return err
+		}
+	} else if implementsUnmarshaler {
+		if err := unmarshaler.UnmarshalText([]byte(env)); err != nil {
+			return err
+		}
+	} else if isPrivateKeyType(f.Type()) {
+		// A key that can't be used is an error even when not strict,
+		// rather than a silently nil key.
+		if err := decodePrivateKey(&f, env); err != nil {
+			return err
+		}
+	} else if unit := unitFor(f.Type(), opts.unit); unit != "" && f.Kind() != reflect.Slice {
+		if err := decodeUnit(&f, env, unit); err != nil && (strict || isRangeError(err)) {
+			return err
+		}
+	} else if f.Kind() == reflect.Slice {
+		if err := d.decodeSlice(&f, env, opts); err != nil && (strict || isRangeError(err)) {
+			return err
+		}
+	} else if f.Kind() == reflect.Map {
+		if err := d.decodeMap(&f, env, opts); err != nil && (strict || isRangeError(err)) {
+			return err
+		}
+	} else {
+		if err := d.decodePrimitive(&f, env, opts); err != nil && strict {
+			return err
+		}
+	}
+
+	if err := opts.validateURLs(f); err != nil {
+		return fmt.Errorf("envdecode: invalid value for \"%s\": %v", opts.name, err)
+	}
+	if err := opts.validateHostPorts(f); err != nil {
+		return fmt.Errorf("envdecode: invalid value for \"%s\": %v", opts.name, err)
+	}
+
+	return nil
+}
+
+// decrypt decrypts contents, if already read from a file or base64
+// decoded, and otherwise the base64 encoded value env.
+func (d *decodeState) decrypt(env string, contents []byte) ([]byte, error) {
+	if d.decryptor == nil {
+		return nil, errors.New("no decryptor configured")
 	}
-
-	// if we didn't do anything - the user probably did something
-	// wrong like leave all fields unexported.
-	if nFields == 0 {
-		return ErrInvalidTarget
+	if contents == nil {
+		var err error
+		if contents, err = base64.StdEncoding.DecodeString(strings.TrimSpace(env)); err != nil {
+			return nil, err
+		}
 	}
+	return d.decryptor(contents)
+}
 
-	return nil
+// trimQuotes removes one pair of matching single or double quotes
+// surrounding s.
+func trimQuotes(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
 }
 
-func decode(target interface{}, strict bool) (int, error) {
-	s := reflect.ValueOf(target)
-	if s.Kind() != reflect.Ptr || s.IsNil() {
-		return 0, ErrInvalidTarget
+// gunzip decompresses b, refusing results larger than maxSize bytes.
+func gunzip(b []byte, maxSize int64) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
 	}
+	defer zr.Close()
 
-	s = s.Elem()
-	if s.Kind() != reflect.Struct {
-		return 0, ErrInvalidTarget
+	out, err := ioutil.ReadAll(io.LimitReader(zr, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > maxSize {
+		return nil, fmt.Errorf("decompressed value is larger than %d bytes", maxSize)
 	}
+	return out, nil
+}
 
-	t := s.Type()
-	setFieldCount := 0
-	for i := 0; i < s.NumField(); i++ {
-		// Localize the umbrella `strict` value to the specific field.
-		strict := strict
+// defaultMaxFileSize is the largest file a "loadfile" field will read
+// unless overridden with "maxsize".
+const defaultMaxFileSize = 1 << 20
 
-		f := s.Field(i)
+// tagOptions holds the parsed contents of an "env" struct tag.
+type tagOptions struct {
+	name            string
+	altName         string
+	separator       string
+	escape          string
+	required        bool
+	hasDefault      bool
+	defaultValue    string
+	strict          bool
+	secret          bool
+	shared          bool
+	encrypted       bool
+	base64          bool
+	gzip            bool
+	json            bool
+	yaml            bool
+	csv             bool
+	csvHeader       bool
+	valueType       string
+	expandHome      bool
+	mustExist       bool
+	dir             bool
+	file            bool
+	hostPort        bool
+	unit            string
+	lenient         bool
+	trimQuotes      bool
+	compose         string
+	from            []string
+	template        string
+	transforms      []string
+	capture         string
+	remaining       bool
+	remainingPrefix string
+	dynamic         bool
+	immutable       bool
+	unset           UnsetPolicy
+	hasUnset        bool
+	prefix          string
+	description     string
+	loadFile        bool
+	maxSize         int64
+	schemes         []string
+	requireHost     bool
 
-		switch f.Kind() {
-		case reflect.Ptr:
-			if f.Elem().Kind() != reflect.Struct {
-				break
-			}
+	// Profile-scoped overrides, keyed by profile name.
+	profileDefaults map[string]string
+	profileRequired map[string]bool
 
-			f = f.Elem()
-			fallthrough
+	// problems lists options that could not be understood; they are
+	// otherwise ignored, and reported only by ValidateStruct.
+	problems []string
+}
 
-		case reflect.Struct:
-			if !f.Addr().CanInterface() {
-				continue
-			}
+// valueTypes are the values understood by the "type" option.
+var valueTypes = map[string]bool{
+	"path": true,
+}
 
-			ss := f.Addr().Interface()
-			_, custom := ss.(Decoder)
-			if custom {
-				break
-			}
+func parseTag(tag string) tagOptions {
+	parts := splitTag(tag)
+	opts := tagOptions{
+		name:      parts[0],
+		separator: ";",
+		maxSize:   defaultMaxFileSize,
+	}
 
-			n, err := decode(ss, strict)
-			if err != nil {
-				return 0, err
+	for _, o := range parts[1:] {
+		switch {
+		case strings.HasPrefix(o, "default@"):
+			if i := strings.Index(o, "="); i > 0 {
+				if opts.profileDefaults == nil {
+					opts.profileDefaults = map[string]string{}
+				}
+				opts.profileDefaults[o[8:i]] = o[i+1:]
+			} else {
+				opts.problems = append(opts.problems, fmt.Sprintf("option %q has no value", o))
 			}
-			setFieldCount += n
+		case strings.HasPrefix(o, "required@"):
+			if opts.profileRequired == nil {
+				opts.profileRequired = map[string]bool{}
+			}
+			opts.profileRequired[o[9:]] = true
+		case strings.HasPrefix(o, "default="):
+			opts.hasDefault = true
+			opts.defaultValue = o[8:]
+		case strings.HasPrefix(o, "desc="):
+			opts.description = o[5:]
+		case strings.HasPrefix(o, "maxsize="):
+			if n, err := strconv.ParseInt(o[8:], 10, 64); err == nil && n > 0 {
+				opts.maxSize = n
+			} else {
+				opts.problems = append(opts.problems, fmt.Sprintf("invalid maxsize %q", o[8:]))
+			}
+		case o == "loadfile":
+			opts.loadFile = true
+		case o == "secret":
+			opts.secret = true
+		case o == "shared":
+			opts.shared = true
+		case o == "encrypted":
+			opts.encrypted = true
+		case o == "base64":
+			opts.base64 = true
+		case o == "gzip":
+			opts.gzip = true
+		case o == "json":
+			opts.json = true
+		case o == "yaml":
+			opts.yaml = true
+		case o == "csv":
+			opts.csv = true
+		case o == "csv=header":
+			opts.csv = true
+			opts.csvHeader = true
+		case strings.HasPrefix(o, "type="):
+			opts.valueType = o[5:]
+			if !valueTypes[opts.valueType] {
+				opts.problems = append(opts.problems, fmt.Sprintf("unknown type %q", opts.valueType))
+			}
+		case o == "expandhome":
+			opts.expandHome = true
+		case o == "mustexist":
+			opts.mustExist = true
+		case o == "dir":
+			opts.dir = true
+		case o == "file":
+			opts.file = true
+		case o == "hostport":
+			opts.hostPort = true
+		case o == "lenient":
+			opts.lenient = true
+		case strings.HasPrefix(o, "unset="):
+			if policy, ok := unsetPolicies[o[6:]]; ok {
+				opts.unset, opts.hasUnset = policy, true
+			} else {
+				opts.problems = append(opts.problems, fmt.Sprintf("unknown unset policy %q", o[6:]))
+			}
+		case o == "immutable":
+			opts.immutable = true
+		case o == "dynamic":
+			opts.dynamic = true
+		case o == "trimquotes":
+			opts.trimQuotes = true
+		case strings.HasPrefix(o, "compose="):
+			opts.compose = o[8:]
+		case o == "remaining":
+			opts.remaining = true
+		case strings.HasPrefix(o, "remaining="):
+			opts.remaining = true
+			opts.remainingPrefix = o[10:]
+		case strings.HasPrefix(o, "prefixcapture="):
+			opts.capture = o[14:]
+			if opts.capture == "" {
+				opts.problems = append(opts.problems, `"prefixcapture" has an empty prefix`)
+			}
+		case strings.HasPrefix(o, "transform="):
+			opts.transforms = strings.Split(o[10:], ";")
+		case strings.HasPrefix(o, "template="):
+			opts.template = o[9:]
+		case strings.HasPrefix(o, "from="):
+			opts.from = strings.Split(o[5:], ";")
+		case o == "escape":
+			opts.escape = `\`
+		case strings.HasPrefix(o, "escape="):
+			if utf8.RuneCountInString(o[7:]) == 1 {
+				opts.escape = o[7:]
+			} else {
+				opts.problems = append(opts.problems, fmt.Sprintf("invalid escape %q", o[7:]))
+			}
+		case strings.HasPrefix(o, "unit="):
+			opts.unit = o[5:]
+			if !units[opts.unit] {
+				opts.problems = append(opts.problems, fmt.Sprintf("unknown unit %q", opts.unit))
+			}
+		case strings.HasPrefix(o, "prefix="):
+			opts.prefix = o[7:]
+		case strings.HasPrefix(o, "schemes="):
+			opts.schemes = strings.Split(o[8:], ";")
+		case o == "requireHost":
+			opts.requireHost = true
+		case strings.HasPrefix(o, "required"):
+			opts.required = true
+		case strings.HasPrefix(o, "strict"):
+			opts.strict = true
+		case o == "":
+		default:
+			opts.problems = append(opts.problems, fmt.Sprintf("unknown option %q", o))
 		}
+	}
 
-		if !f.CanSet() {
-			continue
+	if opts.immutable && opts.dynamic {
+		opts.problems = append(opts.problems, "both immutable and dynamic")
+	}
+	if opts.capture != "" && opts.name != "" {
+		opts.problems = append(opts.problems, `"prefixcapture" fields don't read a variable of their own`)
+	}
+	if opts.remaining && (opts.name != "" || opts.capture != "") {
+		opts.problems = append(opts.problems, `"remaining" fields don't read variables of their own`)
+	}
+	switch {
+	case opts.compose != "" && opts.from == nil:
+		opts.problems = append(opts.problems, `"compose" has no "from" variables`)
+	case opts.compose == "" && opts.from != nil:
+		opts.problems = append(opts.problems, `"from" is only used with "compose"`)
+	case opts.compose != "":
+		values := make([]interface{}, len(opts.from))
+		for i := range values {
+			values[i] = ""
+		}
+		if strings.Contains(fmt.Sprintf(opts.compose, values...), "%!") {
+			opts.problems = append(opts.problems, fmt.Sprintf("compose format %q does not take %d values", opts.compose, len(opts.from)))
 		}
+	}
 
-		tag := t.Field(i).Tag.Get("env")
-		if tag == "" {
-			continue
+	return opts
+}
+
+// structPrefix returns the prefix given by the "prefix" option of the
+// nested struct field sf, if any.
+func structPrefix(sf reflect.StructField) string {
+	opts, _ := fieldTag(sf)
+	return opts.prefix
+}
+
+// splitTag splits an "env" tag on commas.  A comma, equals sign or
+// backslash preceded by a backslash is taken literally, so that
+// options like defaults may contain them.  Other backslashes are left
+// alone.
+func splitTag(tag string) []string {
+	var parts []string
+	var cur strings.Builder
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+		switch {
+		case c == '\\' && i+1 < len(tag) && strings.IndexByte(`,=\`, tag[i+1]) >= 0:
+			i++
+			cur.WriteByte(tag[i])
+		case c == ',':
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
 		}
+	}
+	return append(parts, cur.String())
+}
 
-		parts := strings.Split(tag, ",")
-		env := os.Getenv(parts[0])
+// fieldTag parses the tags of a struct field.  The "env" tag names the
+// variable and may carry every option, but the default, requirement
+// and description may instead be given in separate envDefault,
+// envRequired and envDesc tags, which take precedence.  The boolean
+// result is false if the field has no "env" tag.
+func fieldTag(sf reflect.StructField) (tagOptions, bool) {
+	tag := sf.Tag.Get("env")
+	if tag == "" {
+		return tagOptions{}, false
+	}
+
+	opts := parseTag(tag)
+	if def, ok := sf.Tag.Lookup("envDefault"); ok {
+		opts.hasDefault = true
+		opts.defaultValue = def
+	}
+	if req, ok := sf.Tag.Lookup("envRequired"); ok {
+		var err error
+		if opts.required, err = strconv.ParseBool(req); err != nil {
+			opts.problems = append(opts.problems, fmt.Sprintf("invalid envRequired tag %q", req))
+		}
+	}
+	if desc, ok := sf.Tag.Lookup("envDesc"); ok {
+		opts.description = desc
+	}
+	if tmpl, ok := sf.Tag.Lookup("envTemplate"); ok {
+		opts.template = tmpl
+	}
+	if opts.template != "" {
+		noLookup := func(string) (string, error) { return "", nil }
+		if _, err := parseTemplate(opts.template, noLookup); err != nil {
+			opts.problems = append(opts.problems, fmt.Sprintf("invalid template: %v", err))
+		}
+	}
+	return opts, true
+}
 
-		required := false
-		hasDefault := false
-		defaultValue := ""
+// forProfile returns the options in effect under the named profile.
+// Settings scoped to the profile replace the unscoped ones: a profile
+// default makes the field optional, and a profile requirement drops
+// the unscoped default.
+func (opts tagOptions) forProfile(profile string) tagOptions {
+	if profile == "" {
+		return opts
+	}
 
-		for _, o := range parts[1:] {
-			if !required {
-				required = strings.HasPrefix(o, "required")
-			}
-			if strings.HasPrefix(o, "default=") {
-				hasDefault = true
-				defaultValue = o[8:]
-			}
-			if !strict {
-				strict = strings.HasPrefix(o, "strict")
-			}
+	def, hasDefault := opts.profileDefaults[profile]
+	if hasDefault {
+		opts.hasDefault = true
+		opts.defaultValue = def
+		opts.required = false
+	}
+	if opts.profileRequired[profile] {
+		opts.required = true
+		if !hasDefault {
+			opts.hasDefault = false
+			opts.defaultValue = ""
 		}
+	}
+	return opts
+}
 
-		if required && hasDefault {
-			panic(`envdecode: "default" and "required" may not be specified in the same annotation`)
+// readsAny reports whether the field reads any of the named
+// variables, including through a chained default.
+func (opts tagOptions) readsAny(names map[string]bool) bool {
+	if names[opts.name] || (opts.altName != "" && names[opts.altName]) {
+		return true
+	}
+	for _, name := range opts.from {
+		if names[name] {
+			return true
 		}
-		if env == "" && required {
-			return 0, fmt.Errorf("the environment variable \"%s\" is missing", parts[0])
+	}
+	for name := range names {
+		if opts.capture != "" && strings.HasPrefix(name, opts.capture) {
+			return true
 		}
-		if env == "" {
-			env = defaultValue
+		if opts.remaining && strings.HasPrefix(name, opts.remainingPrefix) {
+			return true
 		}
-		if env == "" {
-			continue
+	}
+	if opts.template != "" {
+		// The variables a template reads aren't known in advance.
+		return true
+	}
+	if isDefaultReference(opts.defaultValue) {
+		for _, link := range strings.Split(opts.defaultValue, "|") {
+			if !isDefaultReference(link) {
+				break
+			}
+			if strings.HasPrefix(link, "$") && names[link[1:]] {
+				return true
+			}
 		}
+	}
+	return false
+}
 
-		setFieldCount++
+// validateURLs checks *url.URL values, or slices of them, against the
+// "schemes" and "requireHost" options.  Other values are ignored.
+func (opts tagOptions) validateURLs(f reflect.Value) error {
+	if len(opts.schemes) == 0 && !opts.requireHost {
+		return nil
+	}
 
-		unmarshaler, implementsUnmarshaler := f.Addr().Interface().(encoding.TextUnmarshaler)
-		decoder, implmentsDecoder := f.Addr().Interface().(Decoder)
-		if implmentsDecoder {
-			if err := decoder.Decode(env); err != nil {
-				return 0, err
-			}
-		} else if implementsUnmarshaler {
-			if err := unmarshaler.UnmarshalText([]byte(env)); err != nil {
-				return 0, err
+	switch v := f.Interface().(type) {
+	case *url.URL:
+		return opts.validateURL(v)
+	case []*url.URL:
+		for _, u := range v {
+			if err := opts.validateURL(u); err != nil {
+				return err
 			}
-		} else if f.Kind() == reflect.Slice {
-			decodeSlice(&f, env)
-		} else {
-			if err := decodePrimitiveType(&f, env); err != nil && strict {
-				return 0, err
+		}
+	}
+	return nil
+}
+
+func (opts tagOptions) validateURL(u *url.URL) error {
+	if u == nil {
+		return nil
+	}
+
+	if len(opts.schemes) > 0 {
+		ok := false
+		for _, scheme := range opts.schemes {
+			if strings.EqualFold(u.Scheme, scheme) {
+				ok = true
+				break
 			}
 		}
+		if !ok {
+			return fmt.Errorf("scheme %q is not one of %s", u.Scheme, strings.Join(opts.schemes, ", "))
+		}
 	}
 
-	return setFieldCount, nil
+	if opts.requireHost && u.Host == "" {
+		return fmt.Errorf("%q has no host", u.String())
+	}
+	return nil
+}
+
+// isPath reports whether the value is a path to be resolved by
+// resolvePath.
+func (opts tagOptions) isPath() bool {
+	return opts.valueType == "path" || opts.expandHome || opts.mustExist || opts.dir || opts.file
+}
+
+// resolvePath expands a leading ~ in p if "expandhome" is given,
+// cleans it, and checks that it exists, and is a directory or regular
+// file, as the "mustexist", "dir" and "file" options require.
+func (opts tagOptions) resolvePath(p string) (string, error) {
+	if opts.expandHome && (p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, "~"+string(filepath.Separator))) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		p = filepath.Join(home, p[1:])
+	}
+	p = filepath.Clean(p)
+
+	if !opts.mustExist && !opts.dir && !opts.file {
+		return p, nil
+	}
+	fi, err := os.Stat(p)
+	switch {
+	case os.IsNotExist(err) && opts.dir:
+		return "", fmt.Errorf("directory %s does not exist", p)
+	case os.IsNotExist(err) && opts.file:
+		return "", fmt.Errorf("file %s does not exist", p)
+	case err != nil:
+		return "", err
+	case opts.dir && !fi.IsDir():
+		return "", fmt.Errorf("%s is not a directory", p)
+	case opts.file && !fi.Mode().IsRegular():
+		return "", fmt.Errorf("%s is not a regular file", p)
+	}
+	return p, nil
 }
 
-func decodeSlice(f *reflect.Value, env string) {
-	parts := strings.Split(env, ";")
+// readFile returns the contents of the regular file at path, refusing
+// to read files larger than maxSize bytes.  If private, files
+// accessible by users other than their owner and group are refused too,
+// except on Windows, where permission bits are not meaningful.
+func readFile(path string, maxSize int64, private bool) ([]byte, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+	if private && runtime.GOOS != "windows" && fi.Mode().Perm()&0007 != 0 {
+		return nil, fmt.Errorf("%s is accessible by other users (mode %v)", path, fi.Mode().Perm())
+	}
+	if fi.Size() > maxSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", path, maxSize)
+	}
+
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	// The file may have grown since it was stat'd.
+	b, err := ioutil.ReadAll(io.LimitReader(fp, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > maxSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", path, maxSize)
+	}
+	return b, nil
+}
+
+// split splits the elements of a slice or map value on the separator.
+// With an escape character, the separator or the escape character
+// itself preceded by it is taken literally; other escape characters are
+// left alone.
+func (opts tagOptions) split(env string) []string {
+	if opts.escape == "" {
+		return strings.Split(env, opts.separator)
+	}
+
+	var parts []string
+	var cur strings.Builder
+	for len(env) > 0 {
+		switch {
+		case strings.HasPrefix(env, opts.escape+opts.separator):
+			cur.WriteString(opts.separator)
+			env = env[len(opts.escape)+len(opts.separator):]
+		case strings.HasPrefix(env, opts.escape+opts.escape):
+			cur.WriteString(opts.escape)
+			env = env[2*len(opts.escape):]
+		case strings.HasPrefix(env, opts.separator):
+			parts = append(parts, cur.String())
+			cur.Reset()
+			env = env[len(opts.separator):]
+		default:
+			_, n := utf8.DecodeRuneInString(env)
+			cur.WriteString(env[:n])
+			env = env[n:]
+		}
+	}
+	return append(parts, cur.String())
+}
+
+// escapeElem escapes the separator and escape character in s, an
+// element of a slice or map value, so that split returns it whole.
+func (opts tagOptions) escapeElem(s string) string {
+	if opts.escape == "" {
+		return s
+	}
+	s = strings.Replace(s, opts.escape, opts.escape+opts.escape, -1)
+	return strings.Replace(s, opts.separator, opts.escape+opts.separator, -1)
+}
+
+func (d *decodeState) decodeSlice(f *reflect.Value, env string, opts tagOptions) error {
+	parts := opts.split(env)
 
 	values := parts[:0]
 	for _, x := range parts {
@@ -212,16 +2154,113 @@ func decodeSlice(f *reflect.Value, env string) {
 		}
 	}
 
+	var firstErr error
 	valuesCount := len(values)
 	slice := reflect.MakeSlice(f.Type(), valuesCount, valuesCount)
-	if valuesCount > 0 {
-		for i := 0; i < valuesCount; i++ {
-			e := slice.Index(i)
-			decodePrimitiveType(&e, values[i])
+	for i := 0; i < valuesCount; i++ {
+		if err := d.decodeElem(slice.Index(i), values[i], opts); err != nil && firstErr == nil {
+			firstErr = err
 		}
 	}
 
 	f.Set(slice)
+	return firstErr
+}
+
+// decodeMap decodes entries of the form key:value, separated like
+// slice elements, into a new map.  Keys and values are decoded like
+// slice elements.  Entries which fail to decode are left out, and the
+// first error is returned.
+func (d *decodeState) decodeMap(f *reflect.Value, env string, opts tagOptions) error {
+	t := f.Type()
+	m := reflect.MakeMap(t)
+
+	var firstErr error
+	for _, entry := range opts.split(env) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		i := strings.IndexByte(entry, ':')
+		if i < 0 {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("map entry %q is not of the form key:value", entry)
+			}
+			continue
+		}
+
+		k := reflect.New(t.Key()).Elem()
+		v := reflect.New(t.Elem()).Elem()
+		err := d.decodeElem(k, strings.TrimSpace(entry[:i]), opts)
+		if err == nil {
+			err = d.decodeElem(v, strings.TrimSpace(entry[i+1:]), opts)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		m.SetMapIndex(k, v)
+	}
+
+	f.Set(m)
+	return firstErr
+}
+
+// decodeElem decodes s into e, an element of a slice or map, in the same way
+// as a field of its type: with a registered decoder, through Decoder or
+// encoding.TextUnmarshaler, with a unit, or as a primitive type.
+// Pointer elements whose types implement Decoder or TextUnmarshaler are
+// allocated.
+func (d *decodeState) decodeElem(e reflect.Value, s string, opts tagOptions) error {
+	if fn := d.typeDecoder(e.Type()); fn != nil {
+		return decodeWithTypeDecoder(&e, fn, s)
+	}
+
+	target := e.Addr()
+	if t := e.Type(); t.Kind() == reflect.Ptr && (t.Implements(decoderType) || t.Implements(textUnmarshalerType)) {
+		e.Set(reflect.New(t.Elem()))
+		target = e
+	}
+	switch v := target.Interface().(type) {
+	case Decoder:
+		return v.Decode(s)
+	case encoding.TextUnmarshaler:
+		return v.UnmarshalText([]byte(s))
+	}
+
+	if unit := unitFor(e.Type(), opts.unit); unit != "" {
+		return decodeUnit(&e, s, unit)
+	}
+	return d.decodePrimitive(&e, s, opts)
+}
+
+// decodePrimitive is decodePrimitiveType, but accepts the lenient
+// spellings of bools if the field or the call asks for them.
+func (d *decodeState) decodePrimitive(f *reflect.Value, env string, opts tagOptions) error {
+	if f.Kind() == reflect.Bool && (opts.lenient || d.lenientBools) {
+		v, err := parseLenientBool(env)
+		if err != nil {
+			return err
+		}
+		f.SetBool(v)
+		return nil
+	}
+	return decodePrimitiveType(f, env)
+}
+
+// parseLenientBool accepts yes/no, y/n, on/off and enabled/disabled, in
+// any case, as well as the values accepted by strconv.ParseBool.
+func parseLenientBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "yes", "y", "on", "enabled", "enable":
+		return true, nil
+	case "no", "n", "off", "disabled", "disable":
+		return false, nil
+	}
+	return strconv.ParseBool(s)
 }
 
 func decodePrimitiveType(f *reflect.Value, env string) error {
@@ -274,150 +2313,102 @@ func decodePrimitiveType(f *reflect.Value, env string) error {
 				return err
 			}
 			f.Set(reflect.ValueOf(v))
+		} else if isPrivateKeyType(f.Type()) {
+			return decodePrivateKey(f, env)
+		}
+
+	case reflect.Interface:
+		if isPrivateKeyType(f.Type()) {
+			return decodePrivateKey(f, env)
 		}
 	}
 	return nil
 }
 
-// MustDecode calls Decode and terminates the process if any errors
-// are encountered.
-func MustDecode(target interface{}) {
-	err := Decode(target)
-	if err != nil {
-		FailureFunc(err)
-	}
-}
+var signerType = reflect.TypeOf((*crypto.Signer)(nil)).Elem()
 
-// MustStrictDecode calls StrictDecode and terminates the process if any errors
-// are encountered.
-func MustStrictDecode(target interface{}) {
-	err := StrictDecode(target)
-	if err != nil {
-		FailureFunc(err)
+// isPrivateKeyType reports whether t is crypto.Signer or a concrete
+// private key type implementing it, such as *rsa.PrivateKey.
+func isPrivateKeyType(t reflect.Type) bool {
+	if t == signerType {
+		return true
 	}
+	return t.Kind() == reflect.Ptr && t.Implements(signerType) &&
+		(t.Elem().PkgPath() == "crypto/rsa" || t.Elem().PkgPath() == "crypto/ecdsa")
 }
 
-//// Configuration info for Export
-
-type ConfigInfo struct {
-	Field        string
-	EnvVar       string
-	Value        string
-	DefaultValue string
-	HasDefault   bool
-	Required     bool
-	UsesEnv      bool
-}
-
-type ConfigInfoSlice []*ConfigInfo
-
-func (c ConfigInfoSlice) Less(i, j int) bool {
-	return c[i].EnvVar < c[j].EnvVar
-}
-func (c ConfigInfoSlice) Len() int {
-	return len(c)
-}
-func (c ConfigInfoSlice) Swap(i, j int) {
-	c[i], c[j] = c[j], c[i]
-}
-
-// Returns a list of final configuration metadata sorted by envvar name
-func Export(target interface{}) ([]*ConfigInfo, error) {
-	s := reflect.ValueOf(target)
-	if s.Kind() != reflect.Ptr || s.IsNil() {
-		return nil, ErrInvalidTarget
+func decodePrivateKey(f *reflect.Value, env string) error {
+	data := []byte(env)
+	if !strings.Contains(env, "-----BEGIN") {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(env))
+		if err != nil {
+			return fmt.Errorf("envdecode: private key is neither PEM nor base64 encoded PEM: %v", err)
+		}
+		data = b
+	} else if !strings.Contains(env, "\n") {
+		// Some platforms can't carry newlines in environment variables
+		// and escape them instead.
+		data = []byte(strings.Replace(env, `\n`, "\n", -1))
 	}
 
-	cfg := []*ConfigInfo{}
-
-	s = s.Elem()
-	if s.Kind() != reflect.Struct {
-		return nil, ErrInvalidTarget
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return errors.New("envdecode: no PEM data found in private key")
 	}
 
-	t := s.Type()
-	for i := 0; i < s.NumField(); i++ {
-		f := s.Field(i)
-		fName := t.Field(i).Name
-
-		fElem := f
-		if f.Kind() == reflect.Ptr {
-			fElem = f.Elem()
-		}
-		if fElem.Kind() == reflect.Struct {
-			ss := fElem.Addr().Interface()
-			subCfg, err := Export(ss)
-			if err != ErrInvalidTarget {
-				f = fElem
-				for _, v := range subCfg {
-					v.Field = fmt.Sprintf("%s.%s", fName, v.Field)
-					cfg = append(cfg, v)
-				}
-			}
-		}
-
-		tag := t.Field(i).Tag.Get("env")
-		if tag == "" {
-			continue
-		}
-
-		parts := strings.Split(tag, ",")
-
-		ci := &ConfigInfo{
-			Field:   fName,
-			EnvVar:  parts[0],
-			UsesEnv: os.Getenv(parts[0]) != "",
-		}
-
-		for _, o := range parts[1:] {
-			if strings.HasPrefix(o, "default=") {
-				ci.HasDefault = true
-				ci.DefaultValue = o[8:]
-			} else if strings.HasPrefix(o, "required") {
-				ci.Required = true
-			}
-		}
-
-		if f.Kind() == reflect.Ptr && f.IsNil() {
-			ci.Value = ""
-		} else if stringer, ok := f.Interface().(fmt.Stringer); ok {
-			ci.Value = stringer.String()
-		} else {
-			switch f.Kind() {
-			case reflect.Bool:
-				ci.Value = strconv.FormatBool(f.Bool())
-
-			case reflect.Float32, reflect.Float64:
-				bits := f.Type().Bits()
-				ci.Value = strconv.FormatFloat(f.Float(), 'f', -1, bits)
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return fmt.Errorf("envdecode: unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return err
+	}
 
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				ci.Value = strconv.FormatInt(f.Int(), 10)
+	v := reflect.ValueOf(key)
+	if !v.Type().AssignableTo(f.Type()) {
+		return fmt.Errorf("envdecode: %T cannot be assigned to %s", key, f.Type())
+	}
+	f.Set(v)
+	return nil
+}
 
-			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-				ci.Value = strconv.FormatUint(f.Uint(), 10)
+// MustDecode calls Decode and terminates the process if any errors
+// are encountered.
+func MustDecode(target interface{}) {
+	mustDecode(target, false)
+}
 
-			case reflect.String:
-				ci.Value = f.String()
+// MustStrictDecode calls StrictDecode and terminates the process if any errors
+// are encountered.
+func MustStrictDecode(target interface{}) {
+	mustDecode(target, true)
+}
 
-			case reflect.Slice:
-				ci.Value = fmt.Sprintf("%v", f.Interface())
+// mustDecode implements MustDecode and MustStrictDecode.
+func mustDecode(target interface{}, strict bool) {
+	d := newDecodeState(nil)
+	d.onError = FieldFailureFunc
+	d.collectErrors = true
 
-			default:
-				// Unable to determine string format for value
-				return nil, ErrInvalidTarget
-			}
+	nFields, err := d.decode(target, strict)
+	if err == nil && len(d.errors) > 0 {
+		err = d.errors
+	}
+	if err == nil && nFields == 0 {
+		err = ErrNoTargetFieldsAreSet
+		if strict {
+			err = ErrInvalidTarget
 		}
-
-		cfg = append(cfg, ci)
 	}
-
-	// No configuration tags found, assume invalid input
-	if len(cfg) == 0 {
-		return nil, ErrInvalidTarget
+	if err != nil {
+		FailureFunc(err)
 	}
-
-	sort.Sort(ConfigInfoSlice(cfg))
-
-	return cfg, nil
 }
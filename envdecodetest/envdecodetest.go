@@ -0,0 +1,103 @@
+// Package envdecodetest provides helpers for testing code that uses
+// envdecode: setting variables for the duration of a test, fake
+// sources, and checking the exported configuration against golden
+// files.
+package envdecodetest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joeshaw/envdecode"
+)
+
+// Setenv sets the variables in env for the duration of the test,
+// restoring their previous values, or unsetting them, when it ends.
+func Setenv(t testing.TB, env map[string]string) {
+	t.Helper()
+	for name, value := range env {
+		restore(t, name)
+		if err := os.Setenv(name, value); err != nil {
+			t.Fatalf("envdecodetest: setting %s: %v", name, err)
+		}
+	}
+}
+
+// Unsetenv unsets the named variables for the duration of the test,
+// restoring them when it ends.
+func Unsetenv(t testing.TB, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		restore(t, name)
+		if err := os.Unsetenv(name); err != nil {
+			t.Fatalf("envdecodetest: unsetting %s: %v", name, err)
+		}
+	}
+}
+
+// restore arranges for the variable name to be restored to its current
+// state when the test ends.
+func restore(t testing.TB, name string) {
+	old, ok := os.LookupEnv(name)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(name, old)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+// Source returns a fake envdecode.Source for the variables in env, for
+// use with envdecode.WithSources.  Values are described as "test".
+func Source(env map[string]string) envdecode.Source {
+	return source(env)
+}
+
+type source map[string]string
+
+func (s source) Lookup(name string) (string, bool, error) {
+	v, ok := s[name]
+	return v, ok, nil
+}
+
+func (s source) Describe(name string) string {
+	return "test"
+}
+
+// UpdateEnv is the variable which, when set to a non-empty value, makes
+// AssertExport write golden files rather than compare with them.
+const UpdateEnv = "ENVDECODETEST_UPDATE"
+
+// AssertExport fails the test unless the envdecode.ExportJSON manifest
+// of target matches the golden file, conventionally in testdata.  Run
+// the tests with ENVDECODETEST_UPDATE=1 to create or update the file.
+func AssertExport(t testing.TB, target interface{}, golden string) {
+	t.Helper()
+
+	got, err := envdecode.ExportJSON(target)
+	if err != nil {
+		t.Fatalf("envdecodetest: exporting: %v", err)
+	}
+
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+			t.Fatalf("envdecodetest: %v", err)
+		}
+		if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+			t.Fatalf("envdecodetest: %v", err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("envdecodetest: %v (run with %s=1 to create it)", err, UpdateEnv)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("envdecodetest: export of %T differs from %s (run with %s=1 to update it)\ngot:\n%s\nwant:\n%s", target, golden, UpdateEnv, got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package envdecodetest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joeshaw/envdecode"
+)
+
+type config struct {
+	Host     string `env:"ENVDECODETEST_HOST,default=localhost"`
+	Port     int    `env:"ENVDECODETEST_PORT"`
+	Password string `env:"ENVDECODETEST_PASSWORD,secret"`
+}
+
+func TestSetenv(t *testing.T) {
+	os.Setenv("ENVDECODETEST_HOST", "original")
+	defer os.Unsetenv("ENVDECODETEST_HOST")
+
+	t.Run("set", func(t *testing.T) {
+		Setenv(t, map[string]string{"ENVDECODETEST_HOST": "example.com", "ENVDECODETEST_PORT": "8080"})
+		Unsetenv(t, "ENVDECODETEST_HOST")
+		if _, ok := os.LookupEnv("ENVDECODETEST_HOST"); ok {
+			t.Fatal("Expected ENVDECODETEST_HOST to be unset")
+		}
+		if os.Getenv("ENVDECODETEST_PORT") != "8080" {
+			t.Fatal("Expected ENVDECODETEST_PORT to be set")
+		}
+	})
+
+	if os.Getenv("ENVDECODETEST_HOST") != "original" {
+		t.Fatalf("Expected ENVDECODETEST_HOST to be restored, got %q", os.Getenv("ENVDECODETEST_HOST"))
+	}
+	if _, ok := os.LookupEnv("ENVDECODETEST_PORT"); ok {
+		t.Fatal("Expected ENVDECODETEST_PORT to be unset again")
+	}
+}
+
+func TestSource(t *testing.T) {
+	src := Source(map[string]string{"ENVDECODETEST_PORT": "8080"})
+
+	var cfg config
+	if err := envdecode.DecodeWithOptions(&cfg, envdecode.WithSources(src)); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 8080 || cfg.Host != "localhost" {
+		t.Fatalf("Unexpected config %+v", cfg)
+	}
+
+	rc, err := envdecode.Preview(&cfg, envdecode.WithSources(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rc[2].EnvVar != "ENVDECODETEST_PORT" || rc[2].Source != "test" {
+		t.Fatalf("Unexpected preview %+v", rc[2])
+	}
+}
+
+func TestAssertExport(t *testing.T) {
+	Setenv(t, map[string]string{"ENVDECODETEST_PORT": "8080", "ENVDECODETEST_PASSWORD": "hunter2"})
+
+	var cfg config
+	if err := envdecode.Decode(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	AssertExport(t, &cfg, filepath.Join("testdata", "config.golden.json"))
+
+	// A golden file is written in update mode, then compared with.
+	golden := filepath.Join(t.TempDir(), "new", "config.json")
+	Setenv(t, map[string]string{UpdateEnv: "1"})
+	AssertExport(t, &cfg, golden)
+	Unsetenv(t, UpdateEnv)
+	AssertExport(t, &cfg, golden)
+}
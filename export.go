@@ -0,0 +1,718 @@
+package envdecode
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//// Configuration info for Export
+
+// redactedValue replaces the values of secret fields in Export.
+const redactedValue = "<redacted>"
+
+// secretPolicy is what exportGroups does with the values of secrets.
+type secretPolicy int
+
+const (
+	redactSecrets secretPolicy = iota // replace them with redactedValue
+	hashSecrets                       // replace them with their hashes
+	revealSecrets                     // leave them as they are
+)
+
+type ConfigInfo struct {
+	Field        string
+	EnvVar       string
+	Value        string
+	DefaultValue string
+	HasDefault   bool
+	Required     bool
+	UsesEnv      bool
+	Description  string
+	Secret       bool
+
+	// Source describes where the value came from: "env", "default",
+	// "file:" and the path of a file read by "loadfile" or a
+	// DirSource, or the description of another Source.  It is set by
+	// Preview, which looks values up; Export leaves it empty.
+	Source string
+
+	// Position is where the field is declared, such as
+	// "config/config.go:42".  It is set by the Apply methods of
+	// Positions, from ParsePositions; Export leaves it empty.
+	Position string
+}
+
+type ConfigInfoSlice []*ConfigInfo
+
+func (c ConfigInfoSlice) Less(i, j int) bool {
+	if c[i].EnvVar != c[j].EnvVar {
+		return c[i].EnvVar < c[j].EnvVar
+	}
+	return c[i].Field < c[j].Field
+}
+func (c ConfigInfoSlice) Len() int {
+	return len(c)
+}
+func (c ConfigInfoSlice) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+// ExportOrder is the order in which Export and its relatives list
+// values, set with WithExportOrder.
+type ExportOrder int
+
+const (
+	// OrderByVariable sorts values by variable name, then field path,
+	// as ConfigInfoSlice does.  It is the default.
+	OrderByVariable ExportOrder = iota
+
+	// OrderByField sorts values by field path.
+	OrderByField
+
+	// OrderByDeclaration lists values in the order their fields are
+	// declared, with those of nested structs in place of the struct
+	// field, so that generated documentation follows the layout of
+	// the configuration.
+	OrderByDeclaration
+)
+
+// WithExportOrder sets the order of the values reported by Export,
+// ExportGroups, ExportJSON, ExportExample, ExportMan, Preview and
+// Handler.
+func WithExportOrder(order ExportOrder) Option {
+	return func(o *options) {
+		o.exportOrder = order
+	}
+}
+
+// sortConfig sorts the values cfg of the struct type t in the order
+// given by WithExportOrder.
+func (d *decodeState) sortConfig(cfg []*ConfigInfo, t reflect.Type) {
+	switch d.exportOrder {
+	case OrderByField:
+		sort.SliceStable(cfg, func(i, j int) bool {
+			return cfg[i].Field < cfg[j].Field
+		})
+	case OrderByDeclaration:
+		pos := map[string]int{}
+		walkFields(t, func(parents []reflect.StructField, sf reflect.StructField, nested bool) {
+			pos[fieldPath(parents, sf)] = len(pos)
+		})
+		sort.SliceStable(cfg, func(i, j int) bool {
+			return pos[cfg[i].Field] < pos[cfg[j].Field]
+		})
+	default:
+		sort.Sort(ConfigInfoSlice(cfg))
+	}
+}
+
+// ConfigGroup holds the configuration metadata of one struct, and the
+// groups of the structs nested within it, so that documentation can be
+// organized into sections mirroring the layout of the configuration.
+type ConfigGroup struct {
+	// Field is the path of the struct field, or empty for the target
+	// itself.
+	Field string
+
+	// Description is taken from the struct field's tags, as for
+	// ConfigInfo.
+	Description string
+
+	// Values are the configuration values of the struct's own fields,
+	// sorted by envvar name.
+	Values []*ConfigInfo
+
+	// Groups are the nested structs with configuration, in the order
+	// they are declared.
+	Groups []*ConfigGroup
+}
+
+// All returns the values of the group and all groups nested within it.
+func (g *ConfigGroup) All() []*ConfigInfo {
+	cfg := append([]*ConfigInfo{}, g.Values...)
+	for _, sub := range g.Groups {
+		cfg = append(cfg, sub.All()...)
+	}
+	return cfg
+}
+
+// Returns a list of final configuration metadata sorted by envvar name,
+// or in the order given by WithExportOrder.  Of the options, only
+// WithRedaction, WithExportOrder and WithTemplateData have an effect.
+func Export(target interface{}, opts ...Option) ([]*ConfigInfo, error) {
+	d := newDecodeState(opts)
+	g, err := exportGroups(target, d, redactSecrets)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := g.All()
+	d.sortConfig(cfg, reflect.TypeOf(target).Elem())
+
+	return cfg, nil
+}
+
+// ExportGroups returns the same configuration metadata as Export, but
+// grouped by the struct each value is declared in.
+func ExportGroups(target interface{}, opts ...Option) (*ConfigGroup, error) {
+	return exportGroups(target, newDecodeState(opts), redactSecrets)
+}
+
+// exportGroups implements ExportGroups, treating the values of secrets
+// as secrets says.
+func exportGroups(target interface{}, d *decodeState, secrets secretPolicy) (*ConfigGroup, error) {
+	s := reflect.ValueOf(target)
+	if s.Kind() != reflect.Ptr || s.IsNil() {
+		return nil, ErrInvalidTarget
+	}
+
+	s = s.Elem()
+	if s.Kind() != reflect.Struct {
+		return nil, ErrInvalidTarget
+	}
+
+	return exportStruct(s, "", "", map[visit]bool{}, d, secrets)
+}
+
+// exportStruct describes the struct s and those nested within it,
+// skipping pointers back to the structs in visiting.
+func exportStruct(s reflect.Value, path, prefix string, visiting map[visit]bool, d *decodeState, secrets secretPolicy) (*ConfigGroup, error) {
+	g := &ConfigGroup{Field: path}
+	v := visit{s.Addr().Pointer(), s.Type()}
+	visiting[v] = true
+	defer delete(visiting, v)
+
+	t := s.Type()
+	for i := 0; i < s.NumField(); i++ {
+		f := s.Field(i)
+		fName := t.Field(i).Name
+		if path != "" {
+			fName = path + "." + fName
+		}
+
+		fElem := f
+		if f.Kind() == reflect.Ptr {
+			fElem = f.Elem()
+		}
+		if fElem.Kind() == reflect.Struct && fElem.Addr().CanInterface() && !isPrivateKeyType(f.Type()) && !visiting[visit{fElem.Addr().Pointer(), fElem.Type()}] {
+			sub, err := exportStruct(fElem, fName, prefix+structPrefix(t.Field(i)), visiting, d, secrets)
+			if err != ErrInvalidTarget {
+				f = fElem
+				sub.Description = fieldDescription(t.Field(i))
+				g.Groups = append(g.Groups, sub)
+			}
+		}
+
+		opts, ok := fieldTag(t.Field(i))
+		if !ok || opts.name == "" {
+			continue
+		}
+		if name, err := d.expandName(opts.name); err == nil {
+			opts.name = name
+		}
+		opts.name = prefix + opts.name
+		opts.secret = opts.secret || d.redacts(opts.name)
+		if d.sliceSeparator != "" && !opts.csv {
+			opts.separator = d.sliceSeparator
+		}
+
+		if t.Field(i).PkgPath != "" {
+			// The values of unexported fields can't be read, but
+			// those with setters are still configuration.
+			if _, ok := setter(t, t.Field(i)); !ok {
+				continue
+			}
+			f = reflect.ValueOf("")
+		}
+
+		ci, err := newConfigInfo(fName, opts, f, os.Getenv(opts.name) != "", "")
+		if err != nil {
+			return nil, err
+		}
+		if secrets != redactSecrets && ci.Value != "" && ci.Secret {
+			v, _ := formatValueWith(f, opts)
+			if secrets == hashSecrets {
+				v = hashValue(v)
+			}
+			ci.Value = v
+		}
+
+		g.Values = append(g.Values, ci)
+	}
+
+	// No configuration tags found, assume invalid input
+	if len(g.Values) == 0 && len(g.Groups) == 0 {
+		return nil, ErrInvalidTarget
+	}
+
+	// Values are already in the order they are declared.
+	if d.exportOrder != OrderByDeclaration {
+		d.sortConfig(g.Values, t)
+	}
+
+	return g, nil
+}
+
+// newConfigInfo describes the field at path with value f, which came
+// from source.
+func newConfigInfo(path string, opts tagOptions, f reflect.Value, usesEnv bool, source string) (*ConfigInfo, error) {
+	v, err := formatValueWith(f, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.secret && v != "" {
+		v = redactedValue
+	}
+
+	return &ConfigInfo{
+		Field:        path,
+		EnvVar:       opts.name,
+		Value:        v,
+		DefaultValue: opts.defaultValue,
+		HasDefault:   opts.hasDefault,
+		Required:     opts.required,
+		UsesEnv:      usesEnv,
+		Description:  opts.description,
+		Secret:       opts.secret,
+		Source:       source,
+	}, nil
+}
+
+// Preview returns the configuration that DecodeWithOptions would
+// produce, in the same form and order as Export, without modifying the
+// target.  It is suitable for a --check-config flag that prints the
+// effective configuration.  Values which can't be formatted are left
+// empty.  Each value's Source tells where it came from, so that audits
+// can check, for instance, that secrets were read from files rather
+// than the environment.
+func Preview(target interface{}, opts ...Option) ([]*ConfigInfo, error) {
+	d := newDecodeState(opts)
+	d.dryRun = true
+
+	cfg := []*ConfigInfo{}
+	d.onField = func(path string, opts tagOptions, r fieldResult) {
+		opts.secret = opts.secret || d.redacts(opts.name)
+		ci, err := newConfigInfo(path, opts, r.value, r.fromEnv, r.source)
+		if err != nil {
+			ci, _ = newConfigInfo(path, opts, reflect.ValueOf(""), r.fromEnv, r.source)
+		}
+		cfg = append(cfg, ci)
+	}
+
+	if _, err := d.decode(target, false); err != nil {
+		return nil, err
+	}
+
+	d.sortConfig(cfg, reflect.TypeOf(target).Elem())
+
+	return cfg, nil
+}
+
+// fieldDescription returns the description given in the tags of sf.
+func fieldDescription(sf reflect.StructField) string {
+	if opts, ok := fieldTag(sf); ok {
+		return opts.description
+	}
+	return sf.Tag.Get("envDesc")
+}
+
+var (
+	typeFormattersMu sync.RWMutex
+	typeFormatters   = map[reflect.Type]func(reflect.Value) string{}
+)
+
+// registerTypeFormatter registers fn to format values of type t for
+// Export, in place of their Go representation.
+func registerTypeFormatter(t reflect.Type, fn func(reflect.Value) string) {
+	typeFormattersMu.Lock()
+	typeFormatters[t] = fn
+	typeFormattersMu.Unlock()
+}
+
+func typeFormatter(t reflect.Type) func(reflect.Value) string {
+	typeFormattersMu.RLock()
+	defer typeFormattersMu.RUnlock()
+	return typeFormatters[t]
+}
+
+// formatValue returns the string representation of a field's value.
+func formatValue(f reflect.Value) (string, error) {
+	return formatValueWith(f, tagOptions{separator: ";"})
+}
+
+// formatValueWith returns the string representation of a field's value
+// in the form it would be decoded from, given the field's tag options:
+// through Encoder, encoding.TextMarshaler or fmt.Stringer if
+// implemented, and with the elements of slices and maps joined by the
+// separator.
+func formatValueWith(f reflect.Value, opts tagOptions) (string, error) {
+	if (f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface) && f.IsNil() {
+		return "", nil
+	} else if fn := typeFormatter(f.Type()); fn != nil {
+		return fn(f), nil
+	} else if isAtomicType(f.Type()) {
+		v := atomicLoad(f)
+		if v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		if !v.IsValid() {
+			return "", nil
+		}
+		return formatValueWith(v, opts)
+	} else if isPrivateKeyType(f.Type()) {
+		// Never expose key material.
+		return fmt.Sprintf("<%T>", f.Interface()), nil
+	} else if encoder, ok := envEncoder(f); ok {
+		return encoder.EncodeEnv()
+	} else if marshaler, ok := textMarshaler(f); ok {
+		b, err := marshaler.MarshalText()
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	} else if stringer, ok := f.Interface().(fmt.Stringer); ok {
+		return stringer.String(), nil
+	}
+
+	switch f.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(f.Bool()), nil
+
+	case reflect.Float32, reflect.Float64:
+		bits := f.Type().Bits()
+		return strconv.FormatFloat(f.Float(), 'f', -1, bits), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(f.Int(), 10), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(f.Uint(), 10), nil
+
+	case reflect.String:
+		return f.String(), nil
+
+	case reflect.Slice:
+		elems := make([]string, f.Len())
+		for i := range elems {
+			s, err := formatValueWith(f.Index(i), opts)
+			if err != nil {
+				return "", err
+			}
+			elems[i] = opts.escapeElem(s)
+		}
+		return strings.Join(elems, opts.separator), nil
+
+	case reflect.Map:
+		entries := make([]string, 0, f.Len())
+		iter := f.MapRange()
+		for iter.Next() {
+			k, err := formatValueWith(iter.Key(), opts)
+			if err != nil {
+				return "", err
+			}
+			v, err := formatValueWith(iter.Value(), opts)
+			if err != nil {
+				return "", err
+			}
+			entries = append(entries, opts.escapeElem(k+":"+v))
+		}
+		// Map iteration order is random; sort for stable output.
+		sort.Strings(entries)
+		return strings.Join(entries, opts.separator), nil
+
+	case reflect.Struct:
+		return fmt.Sprintf("%v", f.Interface()), nil
+
+	case reflect.Ptr:
+		return formatValueWith(f.Elem(), opts)
+	}
+
+	// Unable to determine string format for value
+	return "", ErrInvalidTarget
+}
+
+// envEncoder returns f, or its address if the method has a pointer
+// receiver, as an Encoder.
+func envEncoder(f reflect.Value) (Encoder, bool) {
+	if e, ok := f.Interface().(Encoder); ok {
+		return e, true
+	}
+	if f.CanAddr() {
+		e, ok := f.Addr().Interface().(Encoder)
+		return e, ok
+	}
+	return nil, false
+}
+
+// textMarshaler returns f, or its address if the method has a pointer
+// receiver, as an encoding.TextMarshaler.
+func textMarshaler(f reflect.Value) (encoding.TextMarshaler, bool) {
+	if m, ok := f.Interface().(encoding.TextMarshaler); ok {
+		return m, true
+	}
+	if f.CanAddr() {
+		m, ok := f.Addr().Interface().(encoding.TextMarshaler)
+		return m, ok
+	}
+	return nil, false
+}
+
+// manifest is the document produced by ExportJSON.  Its layout is
+// stable; new fields may be added but existing ones won't change.
+type manifest struct {
+	Version   int                `json:"version"`
+	Variables []manifestVariable `json:"variables"`
+}
+
+type manifestVariable struct {
+	EnvVar       string `json:"env_var"`
+	Field        string `json:"field"`
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Required     bool   `json:"required"`
+	HasDefault   bool   `json:"has_default"`
+	DefaultValue string `json:"default,omitempty"`
+	Secret       bool   `json:"secret"`
+	UsesEnv      bool   `json:"uses_env"`
+	Value        string `json:"value"`
+	Source       string `json:"source,omitempty"`
+}
+
+// ExportJSON returns the metadata from Export as an indented JSON
+// manifest, with the Go type of each field, for consumption by
+// deployment tooling and policy checks.  The values of secret fields
+// are redacted, as are those matching the patterns of WithRedaction.
+func ExportJSON(target interface{}, opts ...Option) ([]byte, error) {
+	cfg, err := Export(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return manifestJSON(target, cfg)
+}
+
+// manifestJSON renders cfg, the metadata of target, as the manifest of
+// ExportJSON, including the Source of each value if known.
+func manifestJSON(target interface{}, cfg []*ConfigInfo) ([]byte, error) {
+	types := map[string]string{}
+	t, _ := structType(target)
+	walkFields(t, func(parents []reflect.StructField, sf reflect.StructField, nested bool) {
+		types[fieldPath(parents, sf)] = sf.Type.String()
+	})
+
+	m := manifest{Version: 1, Variables: make([]manifestVariable, len(cfg))}
+	for i, ci := range cfg {
+		m.Variables[i] = manifestVariable{
+			EnvVar:       ci.EnvVar,
+			Field:        ci.Field,
+			Type:         types[ci.Field],
+			Description:  ci.Description,
+			Required:     ci.Required,
+			HasDefault:   ci.HasDefault,
+			DefaultValue: ci.DefaultValue,
+			Secret:       ci.Secret,
+			UsesEnv:      ci.UsesEnv,
+			Value:        ci.Value,
+			Source:       ci.Source,
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Variables returns the names of the variables read by the struct type
+// of target, sorted and without duplicates, such as for shell
+// completion.  target must be a struct or a pointer to one; only its
+// type is examined.
+func Variables(target interface{}, opts ...Option) ([]string, error) {
+	t, err := structType(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, f := range newDecodeState(opts).envFields(t) {
+		if f.opts.name != "" {
+			names = append(names, f.opts.name)
+		}
+		names = append(names, f.opts.from...)
+	}
+	return uniqueStrings(names), nil
+}
+
+// ExportShell returns an "export VAR=" line for each of the Variables of
+// target, a template to fill in and eval in a shell.
+func ExportShell(target interface{}, opts ...Option) ([]byte, error) {
+	names, err := Variables(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "export %s=\n", name)
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportExample returns an annotated example env file for the struct
+// type of target, to hand to new developers as the starting point of
+// their .env.  Each variable is preceded by its description, with a
+// section for each nested struct.  Defaults are filled in, required
+// variables are left as VAR= to be completed, and secrets are always
+// left blank and marked as such.  Optional variables without a default,
+// and those defaulting to other variables, are commented out.
+func ExportExample(target interface{}, opts ...Option) ([]byte, error) {
+	g, err := ExportGroups(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writeExampleGroup(&buf, g, map[string]bool{})
+	return buf.Bytes(), nil
+}
+
+// writeExampleGroup writes the variables of g and the groups nested
+// within it, skipping those in written.
+func writeExampleGroup(buf *bytes.Buffer, g *ConfigGroup, written map[string]bool) {
+	if g.Field != "" {
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		header := g.Field
+		if g.Description != "" {
+			header += ": " + g.Description
+		}
+		fmt.Fprintf(buf, "# %s\n\n", header)
+	}
+
+	for _, ci := range g.Values {
+		if written[ci.EnvVar] {
+			continue
+		}
+		written[ci.EnvVar] = true
+
+		if ci.Description != "" {
+			fmt.Fprintf(buf, "# %s\n", ci.Description)
+		}
+		switch {
+		case ci.Secret:
+			if ci.Required {
+				buf.WriteString("# Required. Secret: never commit a real value.\n")
+			} else {
+				buf.WriteString("# Secret: never commit a real value.\n")
+			}
+			fmt.Fprintf(buf, "%s=\n", ci.EnvVar)
+		case ci.Required:
+			buf.WriteString("# Required.\n")
+			fmt.Fprintf(buf, "%s=\n", ci.EnvVar)
+		case ci.HasDefault && literalDefault(ci.DefaultValue) != ci.DefaultValue:
+			fmt.Fprintf(buf, "# Defaults to %s.\n", ci.DefaultValue)
+			fmt.Fprintf(buf, "#%s=\n", ci.EnvVar)
+		case ci.HasDefault:
+			fmt.Fprintf(buf, "%s=%s\n", ci.EnvVar, quoteEnvValue(ci.DefaultValue))
+		default:
+			fmt.Fprintf(buf, "#%s=\n", ci.EnvVar)
+		}
+	}
+
+	for _, sub := range g.Groups {
+		writeExampleGroup(buf, sub, written)
+	}
+}
+
+// ExportMan returns a man page ENVIRONMENT section, in roff, describing
+// the variables of the struct type of target, for command-line tools
+// distributed with a man page.  Each variable is listed with its
+// description, whether it is required or secret, and its default, with
+// a subsection for each nested struct.  Include it in a page with ".so"
+// or by concatenation.
+func ExportMan(target interface{}, opts ...Option) ([]byte, error) {
+	g, err := ExportGroups(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(".SH ENVIRONMENT\n")
+	writeManGroup(&buf, g, map[string]bool{})
+	return buf.Bytes(), nil
+}
+
+// writeManGroup writes the variables of g and the groups nested within
+// it, skipping those in written.
+func writeManGroup(buf *bytes.Buffer, g *ConfigGroup, written map[string]bool) {
+	if g.Field != "" {
+		fmt.Fprintf(buf, ".SS %s\n", roffEscape(g.Field))
+		if g.Description != "" {
+			fmt.Fprintf(buf, "%s\n", roffEscape(g.Description))
+		}
+	}
+
+	for _, ci := range g.Values {
+		if written[ci.EnvVar] {
+			continue
+		}
+		written[ci.EnvVar] = true
+
+		fmt.Fprintf(buf, ".TP\n.B %s\n", roffEscape(ci.EnvVar))
+		var notes []string
+		if ci.Description != "" {
+			notes = append(notes, roffEscape(strings.TrimSuffix(ci.Description, "."))+".")
+		}
+		if ci.Required {
+			notes = append(notes, "Required.")
+		}
+		if ci.Secret {
+			notes = append(notes, "Secret.")
+		}
+		if ci.HasDefault && !ci.Secret {
+			notes = append(notes, fmt.Sprintf("Defaults to \\fI%s\\fR.", roffEscape(ci.DefaultValue)))
+		}
+		if len(notes) == 0 {
+			notes = append(notes, "Optional.")
+		}
+		fmt.Fprintf(buf, "%s\n", strings.Join(notes, " "))
+	}
+
+	for _, sub := range g.Groups {
+		writeManGroup(buf, sub, written)
+	}
+}
+
+// roffEscape escapes s for use as text in roff.
+func roffEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, "-", `\-`, "\n", " ").Replace(s)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+// quoteEnvValue quotes v for an env file if it contains spaces, quotes,
+// comments or references to other variables.  Single quotes are used,
+// which dotenv readers take literally, unless v contains one.
+func quoteEnvValue(v string) string {
+	if !strings.ContainsAny(v, " \t\n\"'#$\\`") {
+		return v
+	}
+	if !strings.ContainsAny(v, "'\n") {
+		return "'" + v + "'"
+	}
+	return strconv.Quote(v)
+}
@@ -0,0 +1,431 @@
+package envdecode
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfigGroups struct {
+	Name string `env:"TEST_GROUPS_NAME"`
+
+	Database struct {
+		URL      string `env:"TEST_GROUPS_DATABASE_URL"`
+		PoolSize int    `env:"TEST_GROUPS_DATABASE_POOL_SIZE,default=4"`
+	} `envDesc:"Database settings"`
+
+	HTTP *testGroupsHTTP
+
+	NoConfig noConfig
+}
+
+type testGroupsHTTP struct {
+	Addr string `env:"TEST_GROUPS_HTTP_ADDR,default=:8080"`
+
+	TLS struct {
+		Cert string `env:"TEST_GROUPS_HTTP_TLS_CERT"`
+	} `env:",desc=TLS settings"`
+}
+
+func TestExportGroups(t *testing.T) {
+	os.Setenv("TEST_GROUPS_NAME", "groups")
+	defer os.Unsetenv("TEST_GROUPS_NAME")
+
+	var tc testConfigGroups
+	tc.HTTP = &testGroupsHTTP{}
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := ExportGroups(&tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g.Field != "" || len(g.Values) != 1 || g.Values[0].EnvVar != "TEST_GROUPS_NAME" {
+		t.Fatalf("Unexpected root group %+v", g)
+	}
+	if len(g.Groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %d", len(g.Groups))
+	}
+
+	db := g.Groups[0]
+	if db.Field != "Database" || db.Description != "Database settings" || len(db.Values) != 2 {
+		t.Fatalf("Unexpected database group %+v", db)
+	}
+	if db.Values[0].EnvVar != "TEST_GROUPS_DATABASE_POOL_SIZE" || db.Values[0].Field != "Database.PoolSize" {
+		t.Fatalf("Unexpected database values %+v", db.Values[0])
+	}
+
+	http := g.Groups[1]
+	if http.Field != "HTTP" || len(http.Values) != 1 || len(http.Groups) != 1 {
+		t.Fatalf("Unexpected HTTP group %+v", http)
+	}
+	tls := http.Groups[0]
+	if tls.Field != "HTTP.TLS" || tls.Description != "TLS settings" || tls.Values[0].Field != "HTTP.TLS.Cert" {
+		t.Fatalf("Unexpected TLS group %+v", tls)
+	}
+
+	flat, err := Export(&tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all := g.All(); len(all) != len(flat) {
+		t.Fatalf("Expected %d values, got %d", len(flat), len(all))
+	}
+}
+
+type testConfigManifest struct {
+	Password string        `env:"TEST_MANIFEST_PASSWORD,required,secret"`
+	Timeout  time.Duration `env:"TEST_MANIFEST_TIMEOUT,default=5s,desc=Request timeout"`
+
+	Upstream struct {
+		URL *url.URL `env:"TEST_MANIFEST_UPSTREAM_URL"`
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	os.Setenv("TEST_MANIFEST_PASSWORD", "hunter2")
+	defer os.Unsetenv("TEST_MANIFEST_PASSWORD")
+
+	var tc testConfigManifest
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ExportJSON(&tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "hunter2") {
+		t.Fatalf("Manifest leaked a secret: %s", b)
+	}
+
+	expected := `{
+  "version": 1,
+  "variables": [
+    {
+      "env_var": "TEST_MANIFEST_PASSWORD",
+      "field": "Password",
+      "type": "string",
+      "required": true,
+      "has_default": false,
+      "secret": true,
+      "uses_env": true,
+      "value": "<redacted>"
+    },
+    {
+      "env_var": "TEST_MANIFEST_TIMEOUT",
+      "field": "Timeout",
+      "type": "time.Duration",
+      "description": "Request timeout",
+      "required": false,
+      "has_default": true,
+      "default": "5s",
+      "secret": false,
+      "uses_env": false,
+      "value": "5s"
+    },
+    {
+      "env_var": "TEST_MANIFEST_UPSTREAM_URL",
+      "field": "Upstream.URL",
+      "type": "*url.URL",
+      "required": false,
+      "has_default": false,
+      "secret": false,
+      "uses_env": false,
+      "value": ""
+    }
+  ]
+}
+`
+	if string(b) != expected {
+		t.Fatalf("Unexpected manifest:\n%s", b)
+	}
+}
+
+type testConfigExample struct {
+	Name     string `env:"TEST_EXAMPLE_NAME,required,desc=Service name"`
+	Greeting string `env:"TEST_EXAMPLE_GREETING,default=hello world"`
+	Password string `env:"TEST_EXAMPLE_PASSWORD,default=changeme,secret"`
+	Debug    bool   `env:"TEST_EXAMPLE_DEBUG"`
+
+	Database struct {
+		URL     string `env:"TEST_EXAMPLE_DATABASE_URL,default=$TEST_EXAMPLE_URL"`
+		Workers int    `env:"TEST_EXAMPLE_DATABASE_WORKERS,default=4"`
+	} `envDesc:"Database settings"`
+}
+
+func TestExportExample(t *testing.T) {
+	var tc testConfigExample
+	b, err := ExportExample(&tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `#TEST_EXAMPLE_DEBUG=
+TEST_EXAMPLE_GREETING='hello world'
+# Service name
+# Required.
+TEST_EXAMPLE_NAME=
+# Secret: never commit a real value.
+TEST_EXAMPLE_PASSWORD=
+
+# Database: Database settings
+
+# Defaults to $TEST_EXAMPLE_URL.
+#TEST_EXAMPLE_DATABASE_URL=
+TEST_EXAMPLE_DATABASE_WORKERS=4
+`
+	if string(b) != expected {
+		t.Fatalf("Unexpected example:\n%s", b)
+	}
+	if strings.Contains(string(b), "changeme") {
+		t.Fatalf("Example leaked a secret default: %s", b)
+	}
+}
+
+func TestExportMan(t *testing.T) {
+	b, err := ExportMan(&testConfigExample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `.SH ENVIRONMENT
+.TP
+.B TEST_EXAMPLE_DEBUG
+Optional.
+.TP
+.B TEST_EXAMPLE_GREETING
+Defaults to \fIhello world\fR.
+.TP
+.B TEST_EXAMPLE_NAME
+Service name. Required.
+.TP
+.B TEST_EXAMPLE_PASSWORD
+Secret.
+.SS Database
+Database settings
+.TP
+.B TEST_EXAMPLE_DATABASE_URL
+Defaults to \fI$TEST_EXAMPLE_URL\fR.
+.TP
+.B TEST_EXAMPLE_DATABASE_WORKERS
+Defaults to \fI4\fR.
+`
+	if string(b) != expected {
+		t.Fatalf("Unexpected man page:\n%s", b)
+	}
+
+	if s := roffEscape(`.start -x \n`); s != `\&.start \-x \en` {
+		t.Fatalf("Unexpected escaping %q", s)
+	}
+}
+
+func TestVariables(t *testing.T) {
+	names, err := Variables(testConfigExample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"TEST_EXAMPLE_DATABASE_URL",
+		"TEST_EXAMPLE_DATABASE_WORKERS",
+		"TEST_EXAMPLE_DEBUG",
+		"TEST_EXAMPLE_GREETING",
+		"TEST_EXAMPLE_NAME",
+		"TEST_EXAMPLE_PASSWORD",
+	}
+	if strings.Join(names, " ") != strings.Join(expected, " ") {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+
+	b, err := ExportShell(&testConfigExample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"); len(lines) != len(expected) || lines[0] != "export TEST_EXAMPLE_DATABASE_URL=" {
+		t.Fatalf("Unexpected stubs:\n%s", b)
+	}
+
+	if _, err := Variables(42); err != ErrInvalidTarget {
+		t.Fatalf("Expected ErrInvalidTarget, got %v", err)
+	}
+}
+
+type testConfigPreview struct {
+	Host     string        `env:"TEST_PREVIEW_HOST,default=localhost"`
+	Port     int           `env:"TEST_PREVIEW_PORT"`
+	Timeout  time.Duration `env:"TEST_PREVIEW_TIMEOUT"`
+	Password string        `env:"TEST_PREVIEW_PASSWORD,secret"`
+
+	Nested struct {
+		Name string `env:"TEST_PREVIEW_NAME"`
+	}
+}
+
+func TestPreview(t *testing.T) {
+	os.Setenv("TEST_PREVIEW_PORT", "8080")
+	os.Setenv("TEST_PREVIEW_PASSWORD", "hunter2")
+	os.Setenv("TEST_PREVIEW_NAME", "name")
+	defer os.Unsetenv("TEST_PREVIEW_PORT")
+	defer os.Unsetenv("TEST_PREVIEW_PASSWORD")
+	defer os.Unsetenv("TEST_PREVIEW_NAME")
+
+	tc := testConfigPreview{Timeout: time.Second}
+	rc, err := Preview(&tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*ConfigInfo{
+		{Field: "Host", EnvVar: "TEST_PREVIEW_HOST", Value: "localhost", DefaultValue: "localhost", HasDefault: true, Source: "default"},
+		{Field: "Nested.Name", EnvVar: "TEST_PREVIEW_NAME", Value: "name", UsesEnv: true, Source: "env"},
+		{Field: "Password", EnvVar: "TEST_PREVIEW_PASSWORD", Value: "<redacted>", UsesEnv: true, Secret: true, Source: "env"},
+		{Field: "Port", EnvVar: "TEST_PREVIEW_PORT", Value: "8080", UsesEnv: true, Source: "env"},
+		{Field: "Timeout", EnvVar: "TEST_PREVIEW_TIMEOUT", Value: "1s"},
+	}
+	if len(rc) != len(expected) {
+		t.Fatalf("Have %d results, expected %d", len(rc), len(expected))
+	}
+	for n, v := range rc {
+		if *v != *expected[n] {
+			t.Fatalf("have %+v, expected %+v", v, expected[n])
+		}
+	}
+
+	if tc != (testConfigPreview{Timeout: time.Second}) {
+		t.Fatalf("Preview modified the target: %+v", tc)
+	}
+}
+
+type testConfigExportOrder struct {
+	Zone     string `env:"TEST_ORDER_ZONE"`
+	Database struct {
+		URL string `env:"TEST_ORDER_A_URL"`
+	}
+	Addr string `env:"TEST_ORDER_B_ADDR"`
+}
+
+func TestWithExportOrder(t *testing.T) {
+	var tc testConfigExportOrder
+	for _, test := range []struct {
+		order    ExportOrder
+		expected []string
+	}{
+		{OrderByVariable, []string{"TEST_ORDER_A_URL", "TEST_ORDER_B_ADDR", "TEST_ORDER_ZONE"}},
+		{OrderByField, []string{"TEST_ORDER_B_ADDR", "TEST_ORDER_A_URL", "TEST_ORDER_ZONE"}},
+		{OrderByDeclaration, []string{"TEST_ORDER_ZONE", "TEST_ORDER_A_URL", "TEST_ORDER_B_ADDR"}},
+	} {
+		cfg, err := Export(&tc, WithExportOrder(test.order))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, ci := range cfg {
+			names = append(names, ci.EnvVar)
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Fatalf("Expected %v for order %d, got %v", test.expected, test.order, names)
+		}
+	}
+
+	type flat struct {
+		B string `env:"TEST_ORDER_B"`
+		A string `env:"TEST_ORDER_A"`
+	}
+	b, err := ExportExample(&flat{}, WithExportOrder(OrderByDeclaration))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "#TEST_ORDER_B=\n#TEST_ORDER_A=\n"; string(b) != expected {
+		t.Fatalf("Expected %q, got %q", expected, b)
+	}
+}
+
+type testLevel int
+
+func (l *testLevel) MarshalText() ([]byte, error) {
+	return []byte([]string{"debug", "info"}[*l]), nil
+}
+
+func (l *testLevel) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "debug":
+		*l = 0
+	case "info":
+		*l = 1
+	default:
+		return fmt.Errorf("unknown level %q", b)
+	}
+	return nil
+}
+
+type testConfigExportValues struct {
+	Level  testLevel         `env:"TEST_VALUES_LEVEL"`
+	Levels []testLevel       `env:"TEST_VALUES_LEVELS"`
+	Limits map[string]int    `env:"TEST_VALUES_LIMITS"`
+	Paths  []string          `env:"TEST_VALUES_PATHS,escape"`
+	Labels map[string]string `env:"TEST_VALUES_LABELS"`
+}
+
+func TestExportValues(t *testing.T) {
+	vars := map[string]string{
+		"TEST_VALUES_LEVEL":  "info",
+		"TEST_VALUES_LEVELS": "info;debug",
+		"TEST_VALUES_LIMITS": "b:2;a:1",
+		"TEST_VALUES_PATHS":  `a\;b;c\\d`,
+		"TEST_VALUES_LABELS": "team:core;env:prod",
+	}
+	for k, v := range vars {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	var tc testConfigExportValues
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Export(&tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"TEST_VALUES_LEVEL":  "info",
+		"TEST_VALUES_LEVELS": "info;debug",
+		"TEST_VALUES_LIMITS": "a:1;b:2",
+		"TEST_VALUES_PATHS":  `a\;b;c\\d`,
+		"TEST_VALUES_LABELS": "env:prod;team:core",
+	}
+	for _, ci := range cfg {
+		if ci.Value != expected[ci.EnvVar] {
+			t.Fatalf("Expected %s=%q, got %q", ci.EnvVar, expected[ci.EnvVar], ci.Value)
+		}
+	}
+
+	// Exported values decode back to the same configuration.
+	for _, ci := range cfg {
+		os.Setenv(ci.EnvVar, ci.Value)
+	}
+	var again testConfigExportValues
+	if err := Decode(&again); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, tc) {
+		t.Fatalf("Expected %+v, got %+v", tc, again)
+	}
+
+	cfg, err = Export(&tc, WithSliceSeparator(","))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ci := range cfg {
+		if ci.EnvVar == "TEST_VALUES_LEVELS" && ci.Value != "info,debug" {
+			t.Fatalf("Expected %q, got %q", "info,debug", ci.Value)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package envdecode
+
+import (
+	"expvar"
+)
+
+// PublishExpvar publishes the configuration of target through expvar
+// under name, as a map from variable names to values as reported by
+// Export, with the values of secret fields redacted, so that existing
+// /debug/vars endpoints and scrapers pick it up.  Of the options, only
+// WithRedaction has an effect.  The map is built
+// whenever the variable is read.  Like expvar.Publish, it panics if
+// name is already in use.
+func PublishExpvar(name string, target interface{}, opts ...Option) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		cfg, err := Export(target, opts...)
+		if err != nil {
+			return map[string]string{"error": err.Error()}
+		}
+		m := make(map[string]string, len(cfg))
+		for _, ci := range cfg {
+			m[ci.EnvVar] = ci.Value
+		}
+		return m
+	}))
+}
@@ -0,0 +1,36 @@
+package envdecode
+
+import (
+	"encoding/json"
+	"expvar"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	os.Setenv("TEST_EXPVAR_PASSWORD", "hunter2")
+	defer os.Unsetenv("TEST_EXPVAR_PASSWORD")
+
+	var tc struct {
+		Host     string `env:"TEST_EXPVAR_HOST,default=localhost"`
+		Password string `env:"TEST_EXPVAR_PASSWORD,secret"`
+	}
+	PublishExpvar("test_expvar_config", &tc)
+
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+
+	var m map[string]string
+	if err := json.Unmarshal([]byte(expvar.Get("test_expvar_config").String()), &m); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"TEST_EXPVAR_HOST":     "localhost",
+		"TEST_EXPVAR_PASSWORD": redactedValue,
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("Expected %v, got %v", expected, m)
+	}
+}
@@ -0,0 +1,98 @@
+package envdecode
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// A DecodeError describes a field that could not be decoded.
+type DecodeError struct {
+	Target string // type of the target, such as "main.Config"
+	Field  string // path of the field, such as "Database.Port"
+	EnvVar string // variable the field reads
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeErrors lists every field that could not be decoded.  It is
+// passed to FailureFunc by MustDecode.
+type DecodeErrors []*DecodeError
+
+func (e DecodeErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// missingError is returned for a required variable that is unset.
+type missingError struct {
+	error
+}
+
+// isMissingError reports whether err is, or wraps, a missingError.
+func isMissingError(err error) bool {
+	var me missingError
+	return errors.As(err, &me)
+}
+
+// failureOutput and exit are replaced by tests of JSONFailureFunc.
+var (
+	failureOutput io.Writer = os.Stderr
+	exit                    = os.Exit
+)
+
+// failureJSON is the document written by JSONFailureFunc.
+type failureJSON struct {
+	Level  string             `json:"level"`
+	Msg    string             `json:"msg"`
+	Errors []failureJSONField `json:"errors,omitempty"`
+}
+
+type failureJSONField struct {
+	Target string `json:"target,omitempty"`
+	Field  string `json:"field,omitempty"`
+	EnvVar string `json:"env_var,omitempty"`
+	Error  string `json:"error"`
+}
+
+// JSONFailureFunc is a replacement for FailureFunc, for services with
+// structured logging requirements.  It writes err as a single line of
+// JSON to standard error, listing each field that failed to decode, and
+// terminates the process:
+//
+//	{"level":"fatal","msg":"envdecode: an error was encountered while decoding","errors":[{"target":"main.Config","field":"Port","env_var":"PORT","error":"..."}]}
+//
+// Errors that don't concern a field are reported as "error" in a single
+// entry without a field.
+func JSONFailureFunc(err error) {
+	doc := failureJSON{Level: "fatal", Msg: "envdecode: an error was encountered while decoding"}
+
+	var errs DecodeErrors
+	var de *DecodeError
+	switch {
+	case errors.As(err, &errs):
+	case errors.As(err, &de):
+		errs = DecodeErrors{de}
+	default:
+		doc.Errors = []failureJSONField{{Error: err.Error()}}
+	}
+	for _, e := range errs {
+		doc.Errors = append(doc.Errors, failureJSONField{Target: e.Target, Field: e.Field, EnvVar: e.EnvVar, Error: e.Err.Error()})
+	}
+
+	b, _ := json.Marshal(doc)
+	failureOutput.Write(append(b, '\n'))
+	exit(1)
+}
@@ -0,0 +1,180 @@
+package envdecode
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+type testConfigFailure struct {
+	Host string `env:"TEST_FAILURE_HOST,required"`
+	Port int    `env:"TEST_FAILURE_PORT,strict"`
+
+	Database struct {
+		URL string `env:"TEST_FAILURE_DATABASE_URL,required"`
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	os.Setenv("TEST_FAILURE_PORT", "http")
+	defer os.Unsetenv("TEST_FAILURE_PORT")
+
+	var tc testConfigFailure
+	err := Decode(&tc)
+
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("Expected a DecodeError, got %#v", err)
+	}
+	if de.Field != "Host" || de.EnvVar != "TEST_FAILURE_HOST" || err.Error() != `the environment variable "TEST_FAILURE_HOST" is missing` {
+		t.Fatalf("Unexpected error %+v: %v", de, err)
+	}
+
+	os.Setenv("TEST_FAILURE_HOST", "localhost")
+	defer os.Unsetenv("TEST_FAILURE_HOST")
+	err = Decode(&tc)
+	if !errors.As(err, &de) || de.Field != "Port" || !errors.Is(err, strconv.ErrSyntax) {
+		t.Fatalf("Expected the Port error to be unwrapped, got %#v", err)
+	}
+}
+
+func TestMustDecodeReport(t *testing.T) {
+	os.Setenv("TEST_FAILURE_PORT", "http")
+	defer os.Unsetenv("TEST_FAILURE_PORT")
+	defer func(f func(error)) { FailureFunc = f }(FailureFunc)
+
+	var got error
+	FailureFunc = func(err error) {
+		got = err
+	}
+
+	var tc testConfigFailure
+	MustDecode(&tc)
+
+	errs, ok := got.(DecodeErrors)
+	if !ok {
+		t.Fatalf("Expected DecodeErrors, got %#v", got)
+	}
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field+"="+e.EnvVar)
+	}
+	expected := []string{"Host=TEST_FAILURE_HOST", "Port=TEST_FAILURE_PORT", "Database.URL=TEST_FAILURE_DATABASE_URL"}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("Expected %v, got %v", expected, fields)
+	}
+
+	MustDecode(tc)
+	if got != ErrInvalidTarget {
+		t.Fatalf("Expected ErrInvalidTarget, got %v", got)
+	}
+}
+
+// countingDecoder counts how often it is decoded, failing every time.
+type countingDecoder struct {
+	calls *int
+}
+
+func (c countingDecoder) Decode(string) error {
+	*c.calls++
+	return errors.New("bad value")
+}
+
+func TestMustDecodeReportSinglePass(t *testing.T) {
+	os.Setenv("TEST_FAILURE_COUNTED", "x")
+	defer os.Unsetenv("TEST_FAILURE_COUNTED")
+	defer func(f func(error)) { FailureFunc = f }(FailureFunc)
+
+	var got error
+	FailureFunc = func(err error) {
+		got = err
+	}
+
+	calls := 0
+	tc := struct {
+		Counted countingDecoder `env:"TEST_FAILURE_COUNTED"`
+		Host    string          `env:"TEST_FAILURE_HOST,required"`
+	}{Counted: countingDecoder{&calls}}
+	MustDecode(&tc)
+	if calls != 1 {
+		t.Fatalf("Expected the field to be decoded once, got %d", calls)
+	}
+	if errs, ok := got.(DecodeErrors); !ok || len(errs) != 2 {
+		t.Fatalf("Expected both fields to be reported, got %#v", got)
+	}
+}
+
+func TestJSONFailureFunc(t *testing.T) {
+	var buf bytes.Buffer
+	code := 0
+	defer func(w io.Writer, e func(int)) { failureOutput, exit = w, e }(failureOutput, exit)
+	failureOutput = &buf
+	exit = func(c int) { code = c }
+
+	JSONFailureFunc(DecodeErrors{
+		{Field: "Port", EnvVar: "PORT", Err: errors.New(`invalid value "http"`)},
+	})
+	expected := `{"level":"fatal","msg":"envdecode: an error was encountered while decoding","errors":[{"field":"Port","env_var":"PORT","error":"invalid value \"http\""}]}` + "\n"
+	if buf.String() != expected || code != 1 {
+		t.Fatalf("Unexpected output %q with exit code %d", buf.String(), code)
+	}
+
+	buf.Reset()
+	JSONFailureFunc(ErrInvalidTarget)
+	expected = `{"level":"fatal","msg":"envdecode: an error was encountered while decoding","errors":[{"error":"` + ErrInvalidTarget.Error() + `"}]}` + "\n"
+	if buf.String() != expected {
+		t.Fatalf("Unexpected output %q", buf.String())
+	}
+}
+
+func TestFieldFailureFunc(t *testing.T) {
+	os.Setenv("TEST_FAILURE_HOST", "localhost")
+	os.Setenv("TEST_FAILURE_PORT", "http")
+	defer os.Unsetenv("TEST_FAILURE_HOST")
+	defer os.Unsetenv("TEST_FAILURE_PORT")
+	defer func(f func(error), ff func(*DecodeError) error) { FailureFunc, FieldFailureFunc = f, ff }(FailureFunc, FieldFailureFunc)
+
+	var failed error
+	FailureFunc = func(err error) {
+		failed = err
+	}
+	var seen []*DecodeError
+	FieldFailureFunc = func(err *DecodeError) error {
+		seen = append(seen, err)
+		if err.Field == "Port" {
+			return nil
+		}
+		return err
+	}
+
+	tc := testConfigFailure{Port: 8080}
+	tc.Database.URL = "postgres://fallback"
+	MustDecode(&tc)
+	if len(seen) != 2 {
+		t.Fatalf("Expected two field failures, got %v", seen)
+	}
+	if e := seen[0]; e.Target != "envdecode.testConfigFailure" || e.Field != "Port" || e.EnvVar != "TEST_FAILURE_PORT" {
+		t.Fatalf("Unexpected field failure %+v", e)
+	}
+	if tc.Port != 8080 {
+		t.Fatalf("Expected Port to keep its fallback, got %d", tc.Port)
+	}
+	if errs, ok := failed.(DecodeErrors); !ok || len(errs) != 1 || errs[0].Field != "Database.URL" {
+		t.Fatalf("Expected FailureFunc to get the remaining failures, got %#v", failed)
+	}
+
+	failed, seen = nil, nil
+	os.Setenv("TEST_FAILURE_DATABASE_URL", "postgres://db")
+	defer os.Unsetenv("TEST_FAILURE_DATABASE_URL")
+	MustDecode(&tc)
+	if failed != nil || len(seen) != 1 {
+		t.Fatalf("Expected only the Port failure, got %v and %v", seen, failed)
+	}
+	if tc.Host != "localhost" || tc.Port != 8080 || tc.Database.URL != "postgres://db" {
+		t.Fatalf("Unexpected config %+v", tc)
+	}
+}
@@ -0,0 +1,39 @@
+package envdecode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// Fingerprint returns a stable hash of the configuration held by
+// target, as a hex-encoded SHA-256, so that deployment tooling and logs
+// can tell at a glance whether two instances run identical
+// configuration.  It covers the value of every tagged field, formatted
+// as by Export, along with the variable it is read from.  Secrets
+// contribute only a hash of their values, so a fingerprint reveals
+// nothing about them beyond whether they differ.  The order of fields
+// doesn't matter.
+func Fingerprint(target interface{}) (string, error) {
+	g, err := exportGroups(target, newDecodeState(nil), hashSecrets)
+	if err != nil {
+		return "", err
+	}
+
+	cfg := g.All()
+	sort.Sort(ConfigInfoSlice(cfg))
+
+	h := sha256.New()
+	for _, ci := range cfg {
+		fmt.Fprintf(h, "%q=%q\n", ci.EnvVar, ci.Value)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashValue returns the hash of a secret value standing in for it in
+// Fingerprint.
+func hashValue(v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
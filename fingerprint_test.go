@@ -0,0 +1,52 @@
+package envdecode
+
+import (
+	"strings"
+	"testing"
+)
+
+type testConfigFingerprint struct {
+	Host     string `env:"TEST_FINGERPRINT_HOST"`
+	Port     int    `env:"TEST_FINGERPRINT_PORT"`
+	Password string `env:"TEST_FINGERPRINT_PASSWORD,secret"`
+}
+
+type testConfigFingerprintReordered struct {
+	Password string `env:"TEST_FINGERPRINT_PASSWORD,secret"`
+	Port     int    `env:"TEST_FINGERPRINT_PORT"`
+	Host     string `env:"TEST_FINGERPRINT_HOST"`
+}
+
+func TestFingerprint(t *testing.T) {
+	tc := testConfigFingerprint{Host: "db.internal", Port: 5432, Password: "hunter2"}
+	fp, err := Fingerprint(&tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fp) != 64 || strings.Contains(fp, "hunter2") {
+		t.Fatalf("Expected a hex SHA-256, got %q", fp)
+	}
+
+	same := testConfigFingerprintReordered{Host: "db.internal", Port: 5432, Password: "hunter2"}
+	if other, err := Fingerprint(&same); err != nil || other != fp {
+		t.Fatalf("Expected %q, got %q (%v)", fp, other, err)
+	}
+
+	for _, changed := range []testConfigFingerprint{
+		{Host: "db.internal", Port: 5433, Password: "hunter2"},
+		{Host: "db.internal", Port: 5432, Password: "hunter3"},
+		{Host: "db.internal", Port: 5432},
+	} {
+		other, err := Fingerprint(&changed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other == fp {
+			t.Fatalf("Expected %+v to change the fingerprint", changed)
+		}
+	}
+
+	if _, err := Fingerprint(tc); err != ErrInvalidTarget {
+		t.Fatalf("Expected ErrInvalidTarget, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package envdecode
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// integer is the set of types that RegisterFlags accepts.
+type integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// RegisterFlags registers a decoder for the bitmask type T that accepts
+// the names in flags separated by "|", such as "READ|WRITE|ADMIN", and
+// ORs their bits together, for permission and capability settings.
+// Names are matched without regard to case, and an empty value is
+// zero.  Export reports values of T by their names in the same form.
+// Registering T again replaces its flags.
+func RegisterFlags[T integer](flags map[string]T) {
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if flags[names[i]] != flags[names[j]] {
+			return flags[names[i]] < flags[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	RegisterTypeDecoder(t, func(s string) (interface{}, error) {
+		var v T
+		for _, part := range strings.Split(s, "|") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			bit, ok := lookupName(flags, part)
+			if !ok {
+				return nil, fmt.Errorf("unknown flag %q; expected any of %s", part, strings.Join(names, ", "))
+			}
+			v |= bit
+		}
+		return v, nil
+	})
+	registerTypeFormatter(t, func(f reflect.Value) string {
+		v := T(0)
+		reflect.ValueOf(&v).Elem().Set(f)
+		var set []string
+		var covered T
+		for _, name := range names {
+			if bit := flags[name]; bit != 0 && v&bit == bit && covered&bit != bit {
+				set = append(set, name)
+				covered |= bit
+			}
+		}
+		if v == 0 {
+			for _, name := range names {
+				if flags[name] == 0 {
+					return name
+				}
+			}
+		}
+		if rest := v &^ covered; rest != 0 || set == nil {
+			set = append(set, fmt.Sprint(rest))
+		}
+		return strings.Join(set, "|")
+	})
+}
+
+// lookupName returns the value of name in m, preferring an exact match
+// to one differing in case.
+func lookupName[T any](m map[string]T, name string) (T, bool) {
+	if v, ok := m[name]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	var zero T
+	return zero, false
+}
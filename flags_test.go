@@ -0,0 +1,67 @@
+package envdecode
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+type testPermission uint8
+
+const (
+	testPermRead testPermission = 1 << iota
+	testPermWrite
+	testPermAdmin
+)
+
+type testConfigFlags struct {
+	Perms   testPermission   `env:"TEST_FLAGS_PERMS,default=READ"`
+	Granted []testPermission `env:"TEST_FLAGS_GRANTED"`
+}
+
+func TestRegisterFlags(t *testing.T) {
+	RegisterFlags(map[string]testPermission{
+		"NONE":  0,
+		"READ":  testPermRead,
+		"WRITE": testPermWrite,
+		"ADMIN": testPermAdmin,
+	})
+
+	os.Setenv("TEST_FLAGS_PERMS", "READ | write|ADMIN")
+	os.Setenv("TEST_FLAGS_GRANTED", "READ;READ|WRITE;")
+	defer os.Unsetenv("TEST_FLAGS_PERMS")
+	defer os.Unsetenv("TEST_FLAGS_GRANTED")
+
+	var tc testConfigFlags
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Perms != testPermRead|testPermWrite|testPermAdmin {
+		t.Fatalf("Expected all permissions, got %b", tc.Perms)
+	}
+	if len(tc.Granted) != 2 || tc.Granted[1] != testPermRead|testPermWrite {
+		t.Fatalf("Unexpected granted permissions %v", tc.Granted)
+	}
+
+	for v, expected := range map[testPermission]string{
+		0:                            "NONE",
+		testPermWrite:                "WRITE",
+		testPermRead | testPermAdmin: "READ|ADMIN",
+		testPermWrite | 1<<6:         "WRITE|64",
+	} {
+		tc.Perms = v
+		cfg, err := Export(&tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg[1].Value != expected {
+			t.Fatalf("Expected %b to be exported as %q, got %q", v, expected, cfg[1].Value)
+		}
+	}
+
+	os.Setenv("TEST_FLAGS_PERMS", "READ|EXECUTE")
+	err := StrictDecode(&tc)
+	if err == nil || !strings.Contains(err.Error(), `unknown flag "EXECUTE"; expected any of NONE, READ, WRITE, ADMIN`) {
+		t.Fatalf("Expected an error for an unknown flag, got %v", err)
+	}
+}
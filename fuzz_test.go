@@ -0,0 +1,149 @@
+package envdecode
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The fuzz targets exercise the parsers of tags and values, which are
+// given operator input.  Run one with, for example:
+//
+//	go test -fuzz=FuzzParseTag
+
+func FuzzParseTag(f *testing.F) {
+	for _, seed := range []string{
+		"NAME",
+		"NAME,required",
+		`NAME,default=a\,b,desc=text`,
+		"NAME,default@production=info,required@staging",
+		"NAME,loadfile,maxsize=10,secret",
+		"NAME,unit=bytes,type=path,csv=header",
+		",,,",
+		`\`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, tag string) {
+		opts := parseTag(tag)
+		if parts := splitTag(tag); opts.name != parts[0] {
+			t.Fatalf("parseTag(%q) named %q, splitTag gave %q", tag, opts.name, parts[0])
+		}
+	})
+}
+
+type fuzzConfig struct {
+	Strings   []string          `env:"FUZZ"`
+	Ints      []int             `env:"FUZZ"`
+	Durations []time.Duration   `env:"FUZZ"`
+	Map       map[string]int    `env:"FUZZ"`
+	Bool      bool              `env:"FUZZ,lenient"`
+	Float     float32           `env:"FUZZ"`
+	Uint      uint8             `env:"FUZZ"`
+	Bytes     int64             `env:"FUZZ,unit=bytes"`
+	Percent   float64           `env:"FUZZ,unit=percent"`
+	Rate      float64           `env:"FUZZ,unit=rate"`
+	Decimal   Decimal           `env:"FUZZ"`
+	Rows      []fuzzRow         `env:"FUZZ,csv=header"`
+	Document  map[string]string `env:"FUZZ,json"`
+}
+
+type fuzzRow struct {
+	Name  string
+	Count int
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, seed := range []string{
+		"a;b; c ;;",
+		"1;2;-3",
+		"1s;1m30s",
+		"a:1;b:2;c",
+		"yes",
+		"1.5e3",
+		"512MiB",
+		"85%",
+		"10/500ms",
+		"name,count;a,1",
+		`{"a":"b"}`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		var cfg fuzzConfig
+		src := mapSource{"FUZZ": value}
+		DecodeWithOptions(&cfg, WithSources(src), WithNoPanics())
+		if err := Validate(&cfg, WithSources(src)); err != nil {
+			return
+		}
+		for _, s := range cfg.Strings {
+			if s == "" || strings.Contains(s, ";") || s != strings.TrimSpace(s) {
+				t.Fatalf("%q decoded to invalid element %q", value, s)
+			}
+		}
+	})
+}
+
+func FuzzParseBytes(f *testing.F) {
+	for _, seed := range []string{"0", "512MiB", "10GB", "64k", "1.5K", " 2 MB ", "18446744073709551615"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		n, err := parseBytes(s)
+		if err != nil {
+			return
+		}
+		if m, err := parseBytes(strconv.FormatUint(n, 10)); err != nil || m != n {
+			t.Fatalf("%q parsed as %d, which reparsed as %d, %v", s, n, m, err)
+		}
+	})
+}
+
+func FuzzParseRate(f *testing.F) {
+	for _, seed := range []string{"100", "100/s", "6000/m", "10/500ms", "1/h", "inf", "/min"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if r, err := parseRate(s); err == nil && (r < 0 || math.IsNaN(r)) {
+			t.Fatalf("%q parsed as invalid rate %v", s, r)
+		}
+	})
+}
+
+func FuzzParsePercent(f *testing.F) {
+	for _, seed := range []string{"85%", "12.5", "100 %", "-1", "NaN"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if p, err := parsePercent(s); err == nil && !(p >= 0 && p <= 100) {
+			t.Fatalf("%q parsed as out of range percentage %v", s, p)
+		}
+	})
+}
+
+func FuzzDecimal(f *testing.F) {
+	for _, seed := range []string{"19.99", "-0.005", "1.5e3", "0", "+.5", "1e-3"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		var d Decimal
+		if err := d.Decode(s); err != nil {
+			return
+		}
+		var again Decimal
+		if err := again.Decode(d.String()); err != nil || again.String() != d.String() {
+			t.Fatalf("%q decoded as %s, which decoded as %s, %v", s, d, again, err)
+		}
+	})
+}
+
+func FuzzParseProperties(f *testing.F) {
+	for _, seed := range []string{"a=b", "a: b\\\n  c", "k\\ ey value", "u=\\u00e9\\ud83d\\ude00", "# c\n! d\n", "x=\\u12", "\\"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		parseProperties(s)
+	})
+}
@@ -0,0 +1,49 @@
+package envdecode
+
+import (
+	"net/http"
+)
+
+// Handler returns an http.Handler serving the manifest of ExportJSON for
+// target, with the values of secret fields redacted, so that the
+// effective configuration of a running service can be inspected at an
+// endpoint such as /debug/config.  Each variable also gives the source
+// of its value, such as "env", "default" or "file:/run/secrets/token",
+// as found by Preview when the handler is created; opts should
+// therefore include the options target was decoded with, such as
+// WithSources.  The manifest is generated on each request, so target
+// should not be modified while it is served.
+func Handler(target interface{}, opts ...Option) http.Handler {
+	sources := map[string]string{}
+	if cfg, err := Preview(target, opts...); err == nil {
+		for _, ci := range cfg {
+			sources[ci.Field] = ci.Source
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		cfg, err := Export(target, opts...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, ci := range cfg {
+			ci.Source = sources[ci.Field]
+		}
+		b, err := manifestJSON(target, cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(b)
+	})
+}
@@ -0,0 +1,69 @@
+package envdecode
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	os.Setenv("TEST_HANDLER_PASSWORD", "hunter2")
+	defer os.Unsetenv("TEST_HANDLER_PASSWORD")
+
+	var tc struct {
+		Host     string `env:"TEST_HANDLER_HOST,default=localhost"`
+		Password string `env:"TEST_HANDLER_PASSWORD,secret"`
+	}
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	Handler(&tc).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/config", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Unexpected content type %q", ct)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Variables) != 2 {
+		t.Fatalf("Expected 2 variables, got %+v", m.Variables)
+	}
+	if v := m.Variables[0]; v.EnvVar != "TEST_HANDLER_HOST" || v.Value != "localhost" || v.UsesEnv || v.Source != "default" {
+		t.Fatalf("Unexpected host %+v", v)
+	}
+	if v := m.Variables[1]; v.Value != redactedValue || !v.UsesEnv || v.Source != "env" {
+		t.Fatalf("Expected the password to be redacted and read from env, got %+v", v)
+	}
+
+	// Values from other sources are attributed to them.
+	rec = httptest.NewRecorder()
+	Handler(&tc, WithSources(ValuesSource(url.Values{"TEST_HANDLER_HOST": {"db.internal"}}))).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/config", nil))
+	m = manifest{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if v := m.Variables[0]; v.Source != "query" {
+		t.Fatalf("Expected the host to come from a query source, got %+v", v)
+	}
+
+	rec = httptest.NewRecorder()
+	Handler(&tc).ServeHTTP(rec, httptest.NewRequest("POST", "/debug/config", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected status 405, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	Handler(tc).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/config", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500 for an invalid target, got %d", rec.Code)
+	}
+}
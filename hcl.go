@@ -0,0 +1,129 @@
+package envdecode
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HCLSource returns a Source for the attributes in the HCL file at
+// path, for teams that author service configuration in HCL alongside
+// Terraform or Nomad.  The file is parsed with unmarshal, typically
+// Unmarshal from github.com/hashicorp/hcl, which envdecode doesn't
+// depend on itself, into an interface{} holding maps, lists and
+// scalars.  Attributes are flattened into variables named after the
+// blocks and labels leading to them, mapped as by PropertiesSource, so
+// that
+//
+//	database "primary" {
+//	  host = "db.internal"
+//	}
+//
+// is read by DATABASE_PRIMARY_HOST.  Lists of scalars are joined with
+// semicolons, as slices expect.  The file is read once.  Values are
+// described as "hcl:" followed by path.
+func HCLSource(path string, unmarshal func(data []byte, v interface{}) error) (Source, error) {
+	b, err := readFile(path, defaultMaxFileSize, false)
+	if err != nil {
+		return nil, fmt.Errorf("envdecode: reading %s: %v", path, err)
+	}
+	var v interface{}
+	if err := unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("envdecode: parsing %s: %v", path, err)
+	}
+
+	values := mapSource{}
+	if err := flattenHCL(values, "", reflect.ValueOf(v)); err != nil {
+		return nil, fmt.Errorf("envdecode: parsing %s: %v", path, err)
+	}
+	return fileSource{mapSource: values, desc: "hcl:" + path}, nil
+}
+
+// flattenHCL adds the attributes in v, whose key is key, to values.
+// Blocks are decoded as lists of maps, which are merged.
+func flattenHCL(values mapSource, key string, v reflect.Value) error {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		keys := v.MapKeys()
+		names := make([]string, len(keys))
+		for i, k := range keys {
+			names[i] = fmt.Sprint(k.Interface())
+		}
+		// Flatten in order, so that clashes resolve the same way
+		// every time.
+		order := make([]int, len(keys))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool { return names[order[i]] < names[order[j]] })
+		for _, i := range order {
+			name := names[i]
+			if key != "" {
+				name = key + "." + name
+			}
+			if err := flattenHCL(values, name, v.MapIndex(keys[i])); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Slice, reflect.Array:
+		var scalars []string
+		for i := 0; i < v.Len(); i++ {
+			e := v.Index(i)
+			for e.Kind() == reflect.Interface && !e.IsNil() {
+				e = e.Elem()
+			}
+			if e.Kind() == reflect.Map {
+				if err := flattenHCL(values, key, e); err != nil {
+					return err
+				}
+				continue
+			}
+			s, err := hclScalar(key, e)
+			if err != nil {
+				return err
+			}
+			scalars = append(scalars, s)
+		}
+		if scalars != nil {
+			values[variableName(key)] = strings.Join(scalars, ";")
+		}
+		return nil
+	}
+
+	s, err := hclScalar(key, v)
+	if err != nil {
+		return err
+	}
+	values[variableName(key)] = s
+	return nil
+}
+
+// hclScalar formats the value v of the attribute key.
+func hclScalar(key string, v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Invalid:
+		return "", nil
+	}
+	return "", fmt.Errorf("attribute %s has unsupported type %s", key, v.Type())
+}
@@ -0,0 +1,74 @@
+package envdecode
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHCLSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envdecode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// hcl.Unmarshal decodes blocks as lists of maps, which JSON can
+	// stand in for.
+	path := filepath.Join(dir, "app.hcl")
+	doc := `{
+		"name": "gateway",
+		"debug": true,
+		"ratio": 0.25,
+		"hosts": ["a", "b"],
+		"database": [{"primary": [{"host": "db.internal", "port": 5432}]}],
+		"http-server": [{"addr": ":8080"}, {"timeout": "5s"}]
+	}`
+	if err := ioutil.WriteFile(path, []byte(doc), 0600); err != nil {
+		t.Fatal(err)
+	}
+	src, err := HCLSource(path, json.Unmarshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := fileSource{
+		mapSource: mapSource{
+			"NAME":                  "gateway",
+			"DEBUG":                 "true",
+			"RATIO":                 "0.25",
+			"HOSTS":                 "a;b",
+			"DATABASE_PRIMARY_HOST": "db.internal",
+			"DATABASE_PRIMARY_PORT": "5432",
+			"HTTP_SERVER_ADDR":      ":8080",
+			"HTTP_SERVER_TIMEOUT":   "5s",
+		},
+		desc: "hcl:" + path,
+	}
+	if !reflect.DeepEqual(src, expected) {
+		t.Fatalf("Expected %v, got %v", expected, src)
+	}
+
+	var tc struct {
+		Hosts []string `env:"HOSTS"`
+		Port  int      `env:"DATABASE_PRIMARY_PORT"`
+	}
+	if err := DecodeWithOptions(&tc, WithSources(src)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tc.Hosts, []string{"a", "b"}) || tc.Port != 5432 {
+		t.Fatalf("Unexpected config %+v", tc)
+	}
+
+	failing := func(data []byte, v interface{}) error { return errors.New("bad HCL") }
+	if _, err := HCLSource(path, failing); err == nil {
+		t.Fatal("Expected the parse error")
+	}
+	if _, err := HCLSource(filepath.Join(dir, "missing.hcl"), json.Unmarshal); err == nil {
+		t.Fatal("Expected an error for a missing file")
+	}
+}
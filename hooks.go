@@ -0,0 +1,51 @@
+package envdecode
+
+import "reflect"
+
+// FieldInfo describes the field being decoded to the hooks given to
+// WithPreprocessor and WithPostprocessor.
+type FieldInfo struct {
+	Field  string       // path of the field, such as "Database.Port"
+	EnvVar string       // variable the field reads
+	Type   reflect.Type // type of the field
+	Secret bool         // whether the field is tagged ",secret"
+	Source string       // where the value came from, as in ConfigInfo
+}
+
+// WithPreprocessor calls fn with the raw value of each field, from the
+// environment, a source or its default, before it is converted, and
+// decodes the value fn returns in its place.  It allows normalization
+// that applies to every field, such as stripping byte order marks or
+// resolving references to a secret store, without tagging each one.
+// fn runs after ",trimquotes" and Windows expansion, but before files
+// are loaded and values decoded from base64 or decrypted.  An error
+// from fn fails the field.
+func WithPreprocessor(fn func(field FieldInfo, raw string) (string, error)) Option {
+	return func(o *options) {
+		o.preprocessor = fn
+	}
+}
+
+// WithPostprocessor calls fn with each field that was set, from the
+// environment, a source or its default, and the value it was set to,
+// for concerns that cut across every field such as audit logging or
+// validation spanning types.  fn is called after any setter method, and
+// by Validate and Preview with the value the field would be set to.
+// An error from fn fails the field, though the value stays assigned.
+func WithPostprocessor(fn func(field FieldInfo, value interface{}) error) Option {
+	return func(o *options) {
+		o.postprocessor = fn
+	}
+}
+
+// fieldInfo describes the field being decoded, f, with options opts,
+// whose value came from source.
+func (d *decodeState) fieldInfo(f reflect.Value, opts tagOptions, source string) FieldInfo {
+	return FieldInfo{
+		Field:  d.field,
+		EnvVar: opts.name,
+		Type:   f.Type(),
+		Secret: opts.secret,
+		Source: source,
+	}
+}
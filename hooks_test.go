@@ -0,0 +1,108 @@
+package envdecode
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testConfigHooks struct {
+	Name     string `env:"TEST_HOOKS_NAME"`
+	Port     int    `env:"TEST_HOOKS_PORT,default=8080"`
+	Password string `env:"TEST_HOOKS_PASSWORD,secret"`
+	Nested   struct {
+		Addr string `env:"TEST_HOOKS_ADDR"`
+	}
+}
+
+func TestWithPreprocessor(t *testing.T) {
+	env := []string{
+		"TEST_HOOKS_NAME=\ufeffapp",
+		"TEST_HOOKS_PASSWORD=vault:db",
+		"TEST_HOOKS_ADDR=localhost",
+	}
+
+	var fields []FieldInfo
+	pre := WithPreprocessor(func(field FieldInfo, raw string) (string, error) {
+		fields = append(fields, field)
+		if field.Secret && strings.HasPrefix(raw, "vault:") {
+			return "hunter2", nil
+		}
+		return strings.TrimPrefix(raw, "\ufeff"), nil
+	})
+
+	var tc testConfigHooks
+	if err := DecodeFrom(&tc, env, pre); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Name != "app" {
+		t.Fatalf("Expected %q, got %q", "app", tc.Name)
+	}
+	if tc.Password != "hunter2" {
+		t.Fatalf("Expected %q, got %q", "hunter2", tc.Password)
+	}
+	if tc.Port != 8080 {
+		t.Fatalf("Expected %d, got %d", 8080, tc.Port)
+	}
+
+	expected := []FieldInfo{
+		{Field: "Name", EnvVar: "TEST_HOOKS_NAME", Type: reflect.TypeOf(""), Source: "env"},
+		{Field: "Port", EnvVar: "TEST_HOOKS_PORT", Type: reflect.TypeOf(0), Source: "default"},
+		{Field: "Password", EnvVar: "TEST_HOOKS_PASSWORD", Type: reflect.TypeOf(""), Secret: true, Source: "env"},
+		{Field: "Nested.Addr", EnvVar: "TEST_HOOKS_ADDR", Type: reflect.TypeOf(""), Source: "env"},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, fields)
+	}
+
+	fail := WithPreprocessor(func(field FieldInfo, raw string) (string, error) {
+		if field.EnvVar == "TEST_HOOKS_ADDR" {
+			return "", errors.New("no such host")
+		}
+		return raw, nil
+	})
+	err := DecodeFrom(&testConfigHooks{}, env, fail)
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Field != "Nested.Addr" {
+		t.Fatalf("Expected a DecodeError for Nested.Addr, got %v", err)
+	}
+}
+
+func TestWithPostprocessor(t *testing.T) {
+	env := []string{
+		"TEST_HOOKS_NAME=app",
+		"TEST_HOOKS_ADDR=localhost",
+	}
+
+	values := map[string]interface{}{}
+	post := WithPostprocessor(func(field FieldInfo, value interface{}) error {
+		values[field.Field] = value
+		return nil
+	})
+
+	var tc testConfigHooks
+	if err := DecodeFrom(&tc, env, post); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"Name":        "app",
+		"Port":        8080,
+		"Nested.Addr": "localhost",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("Expected %v, got %v", expected, values)
+	}
+
+	reject := WithPostprocessor(func(field FieldInfo, value interface{}) error {
+		if port, ok := value.(int); ok && port < 1024 {
+			return errors.New("privileged port")
+		}
+		return nil
+	})
+	err := Validate(&testConfigHooks{}, reject, WithSources(EnvironSource(append(env, "TEST_HOOKS_PORT=80"))))
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Field != "Port" {
+		t.Fatalf("Expected a DecodeError for Port, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package envdecode
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+)
+
+// HostPort is a network address such as "db.internal:5432" or
+// "[::1]:8080", split into its host and port.  The port may also be a
+// service name such as "https".
+type HostPort struct {
+	Host string
+	Port int
+}
+
+// Decode implements Decoder.
+func (hp *HostPort) Decode(s string) error {
+	host, port, err := splitHostPort(s)
+	if err != nil {
+		return err
+	}
+	hp.Host, hp.Port = host, port
+	return nil
+}
+
+// String joins the host and port, as net.JoinHostPort does.
+func (hp HostPort) String() string {
+	return net.JoinHostPort(hp.Host, strconv.Itoa(hp.Port))
+}
+
+// splitHostPort splits s with net.SplitHostPort and resolves the port.
+func splitHostPort(s string) (string, int, error) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return "", 0, err
+	}
+	n, err := net.LookupPort("tcp", port)
+	if err != nil {
+		return "", 0, fmt.Errorf("address %s: invalid port %q", s, port)
+	}
+	return host, n, nil
+}
+
+// validateHostPorts checks that string values, or slices of them, are
+// valid host:port addresses for the "hostport" option.
+func (opts tagOptions) validateHostPorts(f reflect.Value) error {
+	if !opts.hostPort {
+		return nil
+	}
+
+	switch {
+	case f.Kind() == reflect.String:
+		_, _, err := splitHostPort(f.String())
+		return err
+	case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String:
+		for i := 0; i < f.Len(); i++ {
+			if _, _, err := splitHostPort(f.Index(i).String()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package envdecode
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+type testConfigHostPort struct {
+	Addr     string   `env:"TEST_HOSTPORT_ADDR,hostport"`
+	Addrs    []string `env:"TEST_HOSTPORT_ADDRS,hostport"`
+	Database HostPort `env:"TEST_HOSTPORT_DATABASE"`
+	Cache    HostPort `env:"TEST_HOSTPORT_CACHE"`
+}
+
+func TestHostPort(t *testing.T) {
+	os.Setenv("TEST_HOSTPORT_ADDR", "localhost:8080")
+	os.Setenv("TEST_HOSTPORT_ADDRS", "a:1;[::1]:2")
+	os.Setenv("TEST_HOSTPORT_DATABASE", "db.internal:5432")
+	os.Setenv("TEST_HOSTPORT_CACHE", "[::1]:https")
+	defer os.Unsetenv("TEST_HOSTPORT_ADDR")
+	defer os.Unsetenv("TEST_HOSTPORT_ADDRS")
+	defer os.Unsetenv("TEST_HOSTPORT_DATABASE")
+	defer os.Unsetenv("TEST_HOSTPORT_CACHE")
+
+	var tc testConfigHostPort
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Addr != "localhost:8080" {
+		t.Fatalf(`Expected "localhost:8080", got %q`, tc.Addr)
+	}
+	if !reflect.DeepEqual(tc.Addrs, []string{"a:1", "[::1]:2"}) {
+		t.Fatalf("Unexpected addresses %v", tc.Addrs)
+	}
+	if tc.Database != (HostPort{Host: "db.internal", Port: 5432}) {
+		t.Fatalf("Unexpected database address %+v", tc.Database)
+	}
+	if tc.Cache != (HostPort{Host: "::1", Port: 443}) {
+		t.Fatalf("Unexpected cache address %+v", tc.Cache)
+	}
+	if s := tc.Cache.String(); s != "[::1]:443" {
+		t.Fatalf(`Expected "[::1]:443", got %q`, s)
+	}
+
+	invalid := []struct {
+		name, value string
+	}{
+		{"TEST_HOSTPORT_ADDR", "localhost"},
+		{"TEST_HOSTPORT_ADDRS", "a:1;b"},
+		{"TEST_HOSTPORT_DATABASE", "db.internal:99999"},
+		{"TEST_HOSTPORT_CACHE", "[::1]:nosuchservice"},
+	}
+	for _, test := range invalid {
+		prev := os.Getenv(test.name)
+		os.Setenv(test.name, test.value)
+		if err := Decode(&tc); err == nil {
+			t.Fatalf("Expected an error decoding %s=%q", test.name, test.value)
+		}
+		os.Setenv(test.name, prev)
+	}
+}
@@ -0,0 +1,68 @@
+package envdecode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// INISource returns a Source for the values in the INI file at path,
+// so that services configured with INI files can decode them through
+// the same struct.  A key in a section is read by the variable named
+// after both, so that
+//
+//	[database]
+//	host = db.internal
+//
+// is read by DATABASE_HOST, while keys before the first section are
+// named on their own.  Names are mapped as by PropertiesSource.  Keys
+// and values are separated by "=" or ":" and trimmed of whitespace, and
+// one pair of double quotes around a value is removed.  Lines starting
+// with ";" or "#" are comments.  The file is read once.  Values are
+// described as "ini:" followed by path.
+func INISource(path string) (Source, error) {
+	b, err := readFile(path, defaultMaxFileSize, false)
+	if err != nil {
+		return nil, fmt.Errorf("envdecode: reading %s: %v", path, err)
+	}
+	values, err := parseINI(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("envdecode: parsing %s: %v", path, err)
+	}
+	return fileSource{mapSource: values, desc: "ini:" + path}, nil
+}
+
+// parseINI parses the contents of an INI file into variables.  A key
+// given more than once takes its last value.
+func parseINI(s string) (mapSource, error) {
+	values := mapSource{}
+	section := ""
+	for n, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return nil, fmt.Errorf("line %d: unterminated section header", n+1)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		i := strings.IndexAny(line, "=:")
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", n+1)
+		}
+		key := strings.TrimSpace(line[:i])
+		if section != "" {
+			key = section + "." + key
+		}
+		value := strings.TrimSpace(line[i+1:])
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+		values[variableName(key)] = value
+	}
+	return values, nil
+}
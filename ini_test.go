@@ -0,0 +1,85 @@
+package envdecode
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseINI(t *testing.T) {
+	values, err := parseINI("; comment\r\n" +
+		"name = gateway\n" +
+		"\n" +
+		"[database]\n" +
+		"# comment\n" +
+		"host = db.internal\n" +
+		"max-conns: 20\n" +
+		"dsn = \"postgres://u:p@db/app?sslmode=disable\"\n" +
+		"\n" +
+		"[ http.server ]\n" +
+		"addr=:8080\n" +
+		"empty =\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := mapSource{
+		"NAME":               "gateway",
+		"DATABASE_HOST":      "db.internal",
+		"DATABASE_MAX_CONNS": "20",
+		"DATABASE_DSN":       "postgres://u:p@db/app?sslmode=disable",
+		"HTTP_SERVER_ADDR":   ":8080",
+		"HTTP_SERVER_EMPTY":  "",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("Expected %q, got %q", expected, values)
+	}
+
+	for _, bad := range []string{"[database", "host"} {
+		if _, err := parseINI(bad); err == nil {
+			t.Fatalf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestINISource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envdecode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.ini")
+	if err := ioutil.WriteFile(path, []byte("[database]\nhost = db.internal\nport = 5432\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	src, err := INISource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tc struct {
+		Database struct {
+			Host string `env:"HOST"`
+			Port int    `env:"PORT"`
+		} `env:",prefix=DATABASE_"`
+	}
+	cfg, err := Preview(&tc, WithSources(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DecodeWithOptions(&tc, WithSources(src)); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Database.Host != "db.internal" || tc.Database.Port != 5432 {
+		t.Fatalf("Unexpected config %+v", tc)
+	}
+	if cfg[0].Source != "ini:"+path {
+		t.Fatalf("Expected the value to come from the file, got %q", cfg[0].Source)
+	}
+
+	if _, err := INISource(filepath.Join(dir, "missing.ini")); err == nil {
+		t.Fatal("Expected an error for a missing file")
+	}
+}
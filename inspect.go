@@ -0,0 +1,96 @@
+package envdecode
+
+import (
+	"encoding"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+var (
+	decoderType         = reflect.TypeOf((*Decoder)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	urlType             = reflect.TypeOf(url.URL{})
+)
+
+// isLeafType reports whether a struct, or pointer to struct, of type t
+// is decoded from a single value rather than recursed into.
+func isLeafType(t reflect.Type) bool {
+	if isPrivateKeyType(t) || typeDecoder(t) != nil {
+		return true
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == urlType || typeDecoder(t) != nil || isAtomicType(t) {
+		return true
+	}
+	pt := reflect.PtrTo(t)
+	return pt.Implements(decoderType) || pt.Implements(textUnmarshalerType)
+}
+
+// walkFields calls fn for every field of the struct type t and of the
+// structs nested within it, depth first, along with the fields leading
+// to it.  nested is true for struct fields that are recursed into.
+// Types already being walked are not entered again, so recursive types
+// terminate.
+func walkFields(t reflect.Type, fn func(parents []reflect.StructField, sf reflect.StructField, nested bool)) {
+	walkFieldsIn(t, nil, map[reflect.Type]bool{}, fn)
+}
+
+func walkFieldsIn(t reflect.Type, parents []reflect.StructField, walking map[reflect.Type]bool, fn func([]reflect.StructField, reflect.StructField, bool)) {
+	walking[t] = true
+	defer delete(walking, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if _, ok := setter(t, sf); sf.PkgPath != "" && !sf.Anonymous && !ok {
+			continue
+		}
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		nested := ft.Kind() == reflect.Struct && !isLeafType(sf.Type)
+		fn(parents, sf, nested)
+
+		if nested && !walking[ft] {
+			walkFieldsIn(ft, append(parents[:len(parents):len(parents)], sf), walking, fn)
+		}
+	}
+}
+
+// fieldPath joins the names of parents and sf with dots.
+func fieldPath(parents []reflect.StructField, sf reflect.StructField) string {
+	names := make([]string, 0, len(parents)+1)
+	for _, p := range parents {
+		names = append(names, p.Name)
+	}
+	return strings.Join(append(names, sf.Name), ".")
+}
+
+// namePrefix concatenates the "prefix" options of parents.
+func namePrefix(parents []reflect.StructField) string {
+	var prefix strings.Builder
+	for _, p := range parents {
+		prefix.WriteString(structPrefix(p))
+	}
+	return prefix.String()
+}
+
+// structType returns the struct type of target, which must be a
+// struct or a pointer to one.
+func structType(target interface{}) (reflect.Type, error) {
+	t := reflect.TypeOf(target)
+	if t == nil {
+		return nil, ErrInvalidTarget
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, ErrInvalidTarget
+	}
+	return t, nil
+}
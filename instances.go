@@ -0,0 +1,83 @@
+package envdecode
+
+import (
+	"errors"
+	"strings"
+)
+
+// DecodeInstances decodes a T for each instance named in the
+// environment by prefixPattern, such as "TENANT_*_", in which * stands
+// for the instance name, for services configured per tenant entirely
+// through the environment.  The variables of each instance are those of
+// T with the pattern, with its name in place of the *, prepended to
+// them, so that with
+//
+//	type Tenant struct {
+//		DatabaseURL string `env:"DATABASE_URL,required"`
+//	}
+//
+// TENANT_ACME_DATABASE_URL configures the tenant "ACME".  Instances
+// are discovered from the names of the variables that T would read
+// under the pattern; where a name could be split in several ways, the
+// shortest instance name is used.  With WithSources, only the sources
+// implementing SourceLister are searched for instances.  Each instance
+// is decoded as by DecodeWithOptions with opts.
+func DecodeInstances[T any](prefixPattern string, opts ...Option) (map[string]T, error) {
+	i := strings.IndexByte(prefixPattern, '*')
+	if i < 0 || strings.Count(prefixPattern, "*") != 1 {
+		return nil, errors.New("envdecode: instance pattern must contain a single *")
+	}
+	before, after := prefixPattern[:i], prefixPattern[i+1:]
+
+	var cfg T
+	t, err := structType(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	d := newDecodeState(opts)
+	var suffixes []string
+	for _, ef := range d.envFields(t) {
+		for _, name := range append([]string{ef.opts.name}, ef.opts.from...) {
+			if name != "" {
+				suffixes = append(suffixes, after+name)
+			}
+		}
+	}
+	names, err := d.names()
+	if err != nil {
+		return nil, err
+	}
+
+	instances := map[string]T{}
+	for _, name := range names {
+		if !strings.HasPrefix(name, before) {
+			continue
+		}
+		rest := name[len(before):]
+		instance := ""
+		for _, suffix := range suffixes {
+			if len(rest) > len(suffix) && strings.HasSuffix(rest, suffix) {
+				if n := rest[:len(rest)-len(suffix)]; instance == "" || len(n) < len(instance) {
+					instance = n
+				}
+			}
+		}
+		if _, ok := instances[instance]; instance == "" || ok {
+			continue
+		}
+
+		var cfg T
+		d := newDecodeState(opts)
+		d.namePrefix = before + instance + after
+		n, err := d.decode(&cfg, false)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, ErrNoTargetFieldsAreSet
+		}
+		instances[instance] = cfg
+	}
+	return instances, nil
+}
@@ -0,0 +1,57 @@
+package envdecode
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+type testConfigTenant struct {
+	URL     string `env:"DB_URL,required"`
+	Workers int    `env:"WORKERS,default=4"`
+}
+
+func TestDecodeInstances(t *testing.T) {
+	os.Setenv("TEST_TENANT_ACME_DB_URL", "postgres://acme")
+	os.Setenv("TEST_TENANT_ACME_WORKERS", "8")
+	os.Setenv("TEST_TENANT_BIG_CORP_DB_URL", "postgres://big-corp")
+	os.Setenv("TEST_TENANT_UNRELATED", "1")
+	defer os.Unsetenv("TEST_TENANT_ACME_DB_URL")
+	defer os.Unsetenv("TEST_TENANT_ACME_WORKERS")
+	defer os.Unsetenv("TEST_TENANT_BIG_CORP_DB_URL")
+	defer os.Unsetenv("TEST_TENANT_UNRELATED")
+
+	tenants, err := DecodeInstances[testConfigTenant]("TEST_TENANT_*_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]testConfigTenant{
+		"ACME":     {URL: "postgres://acme", Workers: 8},
+		"BIG_CORP": {URL: "postgres://big-corp", Workers: 4},
+	}
+	if !reflect.DeepEqual(tenants, expected) {
+		t.Fatalf("Expected %v, got %v", expected, tenants)
+	}
+
+	os.Setenv("TEST_TENANT_BROKEN_WORKERS", "2")
+	defer os.Unsetenv("TEST_TENANT_BROKEN_WORKERS")
+	if _, err := DecodeInstances[testConfigTenant]("TEST_TENANT_*_"); err == nil {
+		t.Fatal("Expected an error for a tenant missing its URL")
+	}
+
+	src, err := QuerySource("TENANT_A_DB_URL=postgres%3A%2F%2Fa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenants, err = DecodeInstances[testConfigTenant]("TENANT_*_", WithSources(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tenants) != 1 || tenants["A"].URL != "postgres://a" {
+		t.Fatalf("Expected tenant A from the query, got %v", tenants)
+	}
+
+	if _, err := DecodeInstances[testConfigTenant]("TEST_TENANT_"); err == nil {
+		t.Fatal("Expected an error for a pattern without *")
+	}
+}
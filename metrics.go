@@ -0,0 +1,75 @@
+package envdecode
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Metrics records the outcomes of the decodes it is given to with
+// WithMetrics, such as the periodic reloads of a long-running service,
+// so that failed reloads and missing variables can be alerted on.
+// envdecode doesn't depend on any monitoring system; the methods are
+// meant to back gauges and counters, such as the CounterFunc and
+// GaugeFunc of the Prometheus client.  The zero value is ready to use,
+// and it is safe for concurrent use.
+type Metrics struct {
+	// Accessed atomically; 64-bit fields come first for alignment.
+	decodes     int64
+	failures    int64
+	lastSuccess int64 // Unix nanoseconds
+	missing     int64
+	defaults    int64
+}
+
+// WithMetrics records the outcome of the decode in m.  Dry runs, such as
+// Validate and Preview, are not recorded.
+func WithMetrics(m *Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// Decodes returns the number of decodes recorded.
+func (m *Metrics) Decodes() int64 {
+	return atomic.LoadInt64(&m.decodes)
+}
+
+// Failures returns the number of decodes that returned an error.
+func (m *Metrics) Failures() int64 {
+	return atomic.LoadInt64(&m.failures)
+}
+
+// LastSuccess returns when a decode last succeeded, or the zero time if
+// none has.
+func (m *Metrics) LastSuccess() time.Time {
+	ns := atomic.LoadInt64(&m.lastSuccess)
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// Missing returns the number of required variables found unset at the
+// last decode.  A decode recording metrics carries on past a missing
+// variable to count the rest before failing with the first.
+func (m *Metrics) Missing() int {
+	return int(atomic.LoadInt64(&m.missing))
+}
+
+// Defaults returns the number of fields given their default values at
+// the last decode.
+func (m *Metrics) Defaults() int {
+	return int(atomic.LoadInt64(&m.defaults))
+}
+
+// record records the outcome of the decode by d.
+func (m *Metrics) record(d *decodeState, err error) {
+	atomic.AddInt64(&m.decodes, 1)
+	if err != nil {
+		atomic.AddInt64(&m.failures, 1)
+	} else {
+		atomic.StoreInt64(&m.lastSuccess, time.Now().UnixNano())
+	}
+	atomic.StoreInt64(&m.missing, int64(len(uniqueStrings(d.missing))))
+	atomic.StoreInt64(&m.defaults, int64(d.defaulted))
+}
@@ -0,0 +1,63 @@
+package envdecode
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfigMetrics struct {
+	Host string `env:"TEST_METRICS_HOST,required"`
+	Port int    `env:"TEST_METRICS_PORT,required"`
+	Name string `env:"TEST_METRICS_NAME,default=app"`
+}
+
+func TestWithMetrics(t *testing.T) {
+	var m Metrics
+	if !m.LastSuccess().IsZero() {
+		t.Fatalf("Expected no last success, got %v", m.LastSuccess())
+	}
+
+	var tc testConfigMetrics
+	err := DecodeWithOptions(&tc, WithMetrics(&m))
+	if err == nil || !strings.Contains(err.Error(), "TEST_METRICS_HOST") {
+		t.Fatalf("Expected the error of the first missing variable, got %v", err)
+	}
+	if m.Decodes() != 1 || m.Failures() != 1 || m.Missing() != 2 || !m.LastSuccess().IsZero() {
+		t.Fatalf("Unexpected metrics after a failure: %d decodes, %d failures, %d missing", m.Decodes(), m.Failures(), m.Missing())
+	}
+
+	os.Setenv("TEST_METRICS_HOST", "localhost")
+	os.Setenv("TEST_METRICS_PORT", "8080")
+	defer os.Unsetenv("TEST_METRICS_HOST")
+	defer os.Unsetenv("TEST_METRICS_PORT")
+
+	before := time.Now()
+	if err := DecodeWithOptions(&tc, WithMetrics(&m)); err != nil {
+		t.Fatal(err)
+	}
+	if m.Decodes() != 2 || m.Failures() != 1 || m.Missing() != 0 || m.Defaults() != 1 || m.LastSuccess().Before(before) {
+		t.Fatalf("Unexpected metrics after a success: %d decodes, %d failures, %d missing, %d defaults, last success %v", m.Decodes(), m.Failures(), m.Missing(), m.Defaults(), m.LastSuccess())
+	}
+
+	if err := Validate(&tc, WithMetrics(&m)); err != nil {
+		t.Fatal(err)
+	}
+	if m.Decodes() != 2 {
+		t.Fatalf("Expected dry runs not to be recorded, got %d decodes", m.Decodes())
+	}
+
+	// Hooks run once per field: metrics don't decode a second time.
+	calls := 0
+	post := WithPostprocessor(func(field FieldInfo, value interface{}) error {
+		calls++
+		return nil
+	})
+	if err := DecodeWithOptions(&tc, WithMetrics(&m), post); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Fatalf("Expected the postprocessor to be called 3 times, got %d", calls)
+	}
+}
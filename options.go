@@ -0,0 +1,305 @@
+package envdecode
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// An Option configures a single call to DecodeWithOptions.
+type Option func(*options)
+
+type options struct {
+	decoders map[reflect.Type]func(string) (interface{}, error)
+	profile  string
+
+	autoPrefix bool
+	naming     NamingStrategy
+
+	privateSecretFiles bool
+
+	sources      []Source
+	runSecrets   bool
+	keepNewlines bool
+	snapshotEnv  bool
+
+	fileDescriptors bool
+
+	decryptor     func([]byte) ([]byte, error)
+	preprocessor  func(FieldInfo, string) (string, error)
+	postprocessor func(FieldInfo, interface{}) error
+
+	lenientBools   bool
+	sliceSeparator string
+
+	windowsExpansion bool
+
+	yamlUnmarshal func([]byte, interface{}) error
+
+	metrics  *Metrics
+	trace    func() func(DecodeStats)
+	warnings func(Warning)
+
+	noPanics bool
+	maxDepth int
+
+	prompt     bool
+	readSecret func() ([]byte, error)
+
+	unset UnsetPolicy
+
+	templateData interface{}
+
+	redactions  []string
+	exportOrder ExportOrder
+
+	renamed       map[string][]string // new names to old ones
+	strictRenames bool
+
+	envconfig       bool
+	envconfigPrefix string
+}
+
+// DecodeWithOptions is like Decode, but its behavior can be adjusted
+// for this call only by the provided options.
+func DecodeWithOptions(target interface{}, opts ...Option) error {
+	nFields, err := newDecodeState(opts).decode(target, false)
+	if err != nil {
+		return err
+	}
+
+	if nFields == 0 {
+		return ErrNoTargetFieldsAreSet
+	}
+
+	return nil
+}
+
+// Once returns a function that decodes a T, as DecodeWithOptions
+// would, the first time it is called, and returns the same
+// configuration and error from then on.  It is safe for concurrent
+// use, so a package can declare
+//
+//	var config = envdecode.Once[Config]()
+//
+// and call config() wherever it needs its settings.
+func Once[T any](opts ...Option) func() (T, error) {
+	var (
+		once sync.Once
+		cfg  T
+		err  error
+	)
+	return func() (T, error) {
+		once.Do(func() {
+			err = DecodeWithOptions(&cfg, opts...)
+		})
+		return cfg, err
+	}
+}
+
+// Validate performs every lookup, requirement check and conversion that
+// DecodeWithOptions would, as if all fields were strict, but writes
+// nothing to the target.  A preflight command or init container can use
+// it to verify the environment before the real process starts.  Like
+// StrictDecode, it rejects variables read by more than one field not
+// marked ",shared".
+func Validate(target interface{}, opts ...Option) error {
+	d := newDecodeState(opts)
+	d.dryRun = true
+
+	nFields, err := d.decode(target, true)
+	if err != nil {
+		return err
+	}
+
+	if nFields == 0 {
+		return ErrNoTargetFieldsAreSet
+	}
+
+	return nil
+}
+
+// ListMissing returns the names of all required variables that are
+// currently unset for target, sorted and without duplicates, so that
+// they can be reported at once rather than one at a time.  The target
+// is not modified.
+func ListMissing(target interface{}, opts ...Option) ([]string, error) {
+	d := newDecodeState(opts)
+	d.dryRun = true
+	d.collectMissing = true
+
+	if _, err := d.decode(target, false); err != nil {
+		return nil, err
+	}
+
+	return uniqueStrings(d.missing), nil
+}
+
+// uniqueStrings sorts s in place and returns it without duplicates.
+func uniqueStrings(s []string) []string {
+	sort.Strings(s)
+	unique := s[:0]
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// WithDecoder uses fn to decode fields and slice elements of type T.
+// Unlike RegisterTypeDecoder it affects only the call it is passed to,
+// and it takes precedence over any decoder registered for T.
+func WithDecoder[T any](fn func(string) (T, error)) Option {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	return func(o *options) {
+		if o.decoders == nil {
+			o.decoders = map[reflect.Type]func(string) (interface{}, error){}
+		}
+		o.decoders[t] = func(s string) (interface{}, error) {
+			return fn(s)
+		}
+	}
+}
+
+// WithProfile selects the deployment profile, such as "production",
+// whose scoped tag options apply.  A field tagged
+// `env:"LOG_LEVEL,default=debug,default@production=info"` defaults to
+// "info" under the production profile and "debug" otherwise, and
+// `env:"DSN,required@production"` is required only in production.
+func WithProfile(name string) Option {
+	return func(o *options) {
+		o.profile = name
+	}
+}
+
+// WithAutoPrefix prefixes the variables of nested structs with the
+// names of the fields leading to them, upper-cased and split into words
+// at case changes, so that short tags suffice inside.  A field tagged
+// `env:"PORT"` in a struct held by the field HTTPServer reads
+// HTTP_SERVER_PORT.  Embedded structs do not contribute a prefix.
+func WithAutoPrefix() Option {
+	return func(o *options) {
+		o.autoPrefix = true
+	}
+}
+
+// A NamingStrategy forms a variable name from words.  With automatic
+// prefixes, it is called with the words of a nested field's name to
+// form its prefix, and with a prefix and the name from an "env" tag to
+// form the variable a field reads; words may therefore be names the
+// strategy formed earlier.
+type NamingStrategy func(words []string) string
+
+// ScreamingSnakeCase joins words with underscores and upper-cases them,
+// as in HTTP_SERVER_PORT.  It is the default naming strategy.
+func ScreamingSnakeCase(words []string) string {
+	return strings.ToUpper(strings.Join(words, "_"))
+}
+
+// SnakeCase joins words with underscores and lower-cases them, as in
+// http_server_port.
+func SnakeCase(words []string) string {
+	return strings.ToLower(strings.Join(words, "_"))
+}
+
+// KebabCase joins words with hyphens and lower-cases them, as in
+// http-server-port.
+func KebabCase(words []string) string {
+	return strings.ToLower(strings.Join(words, "-"))
+}
+
+// WithNaming enables automatic prefixes, like WithAutoPrefix, with
+// names formed by s instead of ScreamingSnakeCase.
+func WithNaming(s NamingStrategy) Option {
+	return func(o *options) {
+		o.autoPrefix = true
+		o.naming = s
+	}
+}
+
+// WithPrivateSecretFiles rejects the files loaded by fields tagged both
+// ",secret" and ",loadfile", and those read by a DirSource, if users
+// other than the file's owner and group may access them, as with mode
+// 0644, so that misconfigured secret mounts are caught at startup.
+// Modes such as 0600 and 0640 are accepted.  The check is skipped on
+// Windows.
+func WithPrivateSecretFiles() Option {
+	return func(o *options) {
+		o.privateSecretFiles = true
+	}
+}
+
+// WithDecryptor decrypts the values of fields tagged ",encrypted" with
+// fn, such as an AES-GCM or age decryption, before they are decoded.
+// Decoding an encrypted field without a decryptor fails.
+func WithDecryptor(fn func(ciphertext []byte) ([]byte, error)) Option {
+	return func(o *options) {
+		o.decryptor = fn
+	}
+}
+
+// WithLenientBools accepts yes/no, y/n, on/off and enabled/disabled, in
+// any case, for all bools, as the ",lenient" tag option does for a
+// single field.
+func WithLenientBools() Option {
+	return func(o *options) {
+		o.lenientBools = true
+	}
+}
+
+// WithSliceSeparator separates the elements of all slices, and the
+// entries of all maps, with sep instead of a semicolon, for projects
+// standardizing on another separator such as a comma.  Slices decoded
+// from CSV are unaffected.
+func WithSliceSeparator(sep string) Option {
+	return func(o *options) {
+		o.sliceSeparator = sep
+	}
+}
+
+// WithWindowsExpansion replaces references to variables written in the
+// Windows style, %NAME%, within values and defaults, so that
+// configuration written for Windows services, such as
+// LOG_DIR=%ProgramData%\app\logs, expands the same way on every
+// platform.  As in cmd.exe, "%%" is a literal percent sign and
+// references to unset variables are left as they are.
+func WithWindowsExpansion() Option {
+	return func(o *options) {
+		o.windowsExpansion = true
+	}
+}
+
+// WithYAML unmarshals the values of fields tagged ",yaml" with fn,
+// typically Unmarshal from gopkg.in/yaml.v3 or sigs.k8s.io/yaml, which
+// envdecode doesn't depend on itself.  Decoding a YAML field without it
+// fails.
+func WithYAML(fn func(data []byte, v interface{}) error) Option {
+	return func(o *options) {
+		o.yamlUnmarshal = fn
+	}
+}
+
+// WithNoPanics guarantees that decoding returns an error rather than
+// panicking, as it otherwise does for a field that is both required and
+// defaulted, and converts any panic raised while decoding, such as by a
+// Decoder, into a DecodeError for the field being decoded.  It is meant
+// for library code that must not bring down the program it runs in.
+func WithNoPanics() Option {
+	return func(o *options) {
+		o.noPanics = true
+	}
+}
+
+// WithMaxDepth fails decoding targets whose structs are nested more than
+// n deep, counting the target itself as 0, rather than exhausting the
+// stack on pathological types.  The default is 64.  Whatever the depth,
+// nil pointers to a struct type that is already being decoded are left
+// nil, and pointers back to a struct that is being decoded are an
+// error.
+func WithMaxDepth(n int) Option {
+	return func(o *options) {
+		o.maxDepth = n
+	}
+}
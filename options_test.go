@@ -0,0 +1,682 @@
+package envdecode
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testConfigWithDecoder struct {
+	Upper  upperString   `env:"TEST_WITH_DECODER"`
+	Uppers []upperString `env:"TEST_WITH_DECODER_SLICE"`
+}
+
+type upperString string
+
+func TestWithDecoder(t *testing.T) {
+	os.Setenv("TEST_WITH_DECODER", "hello")
+	os.Setenv("TEST_WITH_DECODER_SLICE", "a;b")
+	defer os.Unsetenv("TEST_WITH_DECODER")
+	defer os.Unsetenv("TEST_WITH_DECODER_SLICE")
+
+	upper := WithDecoder(func(s string) (upperString, error) {
+		return upperString(strings.ToUpper(s)), nil
+	})
+
+	var tc testConfigWithDecoder
+	if err := DecodeWithOptions(&tc, upper); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Upper != "HELLO" {
+		t.Fatalf(`Expected "HELLO", got %q`, tc.Upper)
+	}
+	if !reflect.DeepEqual(tc.Uppers, []upperString{"A", "B"}) {
+		t.Fatalf("Expected [A B], got %v", tc.Uppers)
+	}
+
+	// The decoder is scoped to the call it was passed to.
+	var plain testConfigWithDecoder
+	if err := Decode(&plain); err != nil {
+		t.Fatal(err)
+	}
+	if plain.Upper != "hello" {
+		t.Fatalf(`Expected "hello", got %q`, plain.Upper)
+	}
+
+	// And takes precedence over the global registry.
+	RegisterTypeDecoder(reflect.TypeOf(upperString("")), func(s string) (interface{}, error) {
+		return upperString("registered"), nil
+	})
+	defer func() {
+		typeDecodersMu.Lock()
+		delete(typeDecoders, reflect.TypeOf(upperString("")))
+		typeDecodersMu.Unlock()
+	}()
+	if err := DecodeWithOptions(&tc, upper); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Upper != "HELLO" {
+		t.Fatalf(`Expected "HELLO", got %q`, tc.Upper)
+	}
+}
+
+type testConfigProfile struct {
+	LogLevel string `env:"TEST_PROFILE_LOG_LEVEL,default=debug,default@production=info"`
+	DSN      string `env:"TEST_PROFILE_DSN,default=sqlite://,required@production"`
+	Token    string `env:"TEST_PROFILE_TOKEN,required,default@development=dev-token"`
+}
+
+func TestWithProfile(t *testing.T) {
+	os.Setenv("TEST_PROFILE_TOKEN", "token")
+	defer os.Unsetenv("TEST_PROFILE_TOKEN")
+	defer os.Unsetenv("TEST_PROFILE_DSN")
+
+	var tc testConfigProfile
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+	if tc.LogLevel != "debug" || tc.DSN != "sqlite://" {
+		t.Fatalf("Expected unscoped defaults, got %+v", tc)
+	}
+
+	tc = testConfigProfile{}
+	if err := DecodeWithOptions(&tc, WithProfile("production")); err == nil {
+		t.Fatal("Expected an error for a variable required in production")
+	}
+
+	os.Setenv("TEST_PROFILE_DSN", "postgres://db")
+	tc = testConfigProfile{}
+	if err := DecodeWithOptions(&tc, WithProfile("production")); err != nil {
+		t.Fatal(err)
+	}
+	if tc.LogLevel != "info" || tc.DSN != "postgres://db" {
+		t.Fatalf("Expected production settings, got %+v", tc)
+	}
+
+	os.Unsetenv("TEST_PROFILE_TOKEN")
+	tc = testConfigProfile{}
+	if err := DecodeWithOptions(&tc, WithProfile("development")); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Token != "dev-token" {
+		t.Fatalf(`Expected "dev-token", got %q`, tc.Token)
+	}
+}
+
+type testConfigValidate struct {
+	Host    string        `env:"TEST_VALIDATE_HOST,required"`
+	Port    int           `env:"TEST_VALIDATE_PORT,default=80"`
+	Timeout time.Duration `env:"TEST_VALIDATE_TIMEOUT"`
+	Hosts   []string      `env:"TEST_VALIDATE_HOSTS"`
+
+	Nested *struct {
+		Name string `env:"TEST_VALIDATE_NAME"`
+	}
+}
+
+func TestValidate(t *testing.T) {
+	defer os.Unsetenv("TEST_VALIDATE_HOST")
+	defer os.Unsetenv("TEST_VALIDATE_TIMEOUT")
+	defer os.Unsetenv("TEST_VALIDATE_HOSTS")
+	defer os.Unsetenv("TEST_VALIDATE_NAME")
+
+	var tc testConfigValidate
+	if err := Validate(&tc); err == nil {
+		t.Fatal("Expected an error for a missing required variable")
+	}
+
+	os.Setenv("TEST_VALIDATE_HOST", "example.com")
+	os.Setenv("TEST_VALIDATE_TIMEOUT", "ten seconds")
+	if err := Validate(&tc); err == nil {
+		t.Fatal("Expected an error for an invalid duration")
+	}
+
+	os.Setenv("TEST_VALIDATE_TIMEOUT", "10s")
+	os.Setenv("TEST_VALIDATE_HOSTS", "a;b")
+	os.Setenv("TEST_VALIDATE_NAME", "name")
+	tc.Nested = &struct {
+		Name string `env:"TEST_VALIDATE_NAME"`
+	}{}
+	if err := Validate(&tc); err != nil {
+		t.Fatal(err)
+	}
+
+	if tc.Host != "" || tc.Port != 0 || tc.Timeout != 0 || tc.Hosts != nil || tc.Nested.Name != "" {
+		t.Fatalf("Validate modified the target: %+v", tc)
+	}
+}
+
+type testConfigMissing struct {
+	A string `env:"TEST_MISSING_A,required"`
+	B string `env:"TEST_MISSING_B,required"`
+	C string `env:"TEST_MISSING_C,default=c"`
+	D string `env:"TEST_MISSING_D,required"`
+
+	Nested struct {
+		A string `env:"TEST_MISSING_A,required"`
+		E string `env:"TEST_MISSING_E,required@production"`
+	}
+}
+
+func TestListMissing(t *testing.T) {
+	os.Setenv("TEST_MISSING_B", "b")
+	defer os.Unsetenv("TEST_MISSING_B")
+
+	var tc testConfigMissing
+	missing, err := ListMissing(&tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(missing, []string{"TEST_MISSING_A", "TEST_MISSING_D"}) {
+		t.Fatalf("Unexpected missing variables %v", missing)
+	}
+
+	missing, err = ListMissing(&tc, WithProfile("production"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(missing, []string{"TEST_MISSING_A", "TEST_MISSING_D", "TEST_MISSING_E"}) {
+		t.Fatalf("Unexpected missing variables %v", missing)
+	}
+
+	if tc.B != "" {
+		t.Fatal("ListMissing modified the target")
+	}
+}
+
+type testConfigAutoPrefix struct {
+	Name string `env:"NAME"`
+
+	HTTPServer struct {
+		Port int `env:"PORT,default=8080"`
+
+		TLS *struct {
+			Cert string `env:"CERT"`
+		}
+	}
+
+	AutoPrefixEmbedded
+}
+
+type AutoPrefixEmbedded struct {
+	Debug bool `env:"DEBUG"`
+}
+
+func TestWithAutoPrefix(t *testing.T) {
+	os.Setenv("NAME", "app")
+	os.Setenv("HTTP_SERVER_PORT", "9090")
+	os.Setenv("HTTP_SERVER_TLS_CERT", "cert.pem")
+	os.Setenv("DEBUG", "true")
+	defer os.Unsetenv("NAME")
+	defer os.Unsetenv("HTTP_SERVER_PORT")
+	defer os.Unsetenv("HTTP_SERVER_TLS_CERT")
+	defer os.Unsetenv("DEBUG")
+
+	var tc testConfigAutoPrefix
+	tc.HTTPServer.TLS = &struct {
+		Cert string `env:"CERT"`
+	}{}
+	if err := DecodeWithOptions(&tc, WithAutoPrefix()); err != nil {
+		t.Fatal(err)
+	}
+
+	if tc.Name != "app" {
+		t.Fatalf(`Expected "app", got %q`, tc.Name)
+	}
+	if tc.HTTPServer.Port != 9090 {
+		t.Fatalf("Expected 9090, got %d", tc.HTTPServer.Port)
+	}
+	if tc.HTTPServer.TLS.Cert != "cert.pem" {
+		t.Fatalf(`Expected "cert.pem", got %q`, tc.HTTPServer.TLS.Cert)
+	}
+	if !tc.Debug {
+		t.Fatal("Expected embedded field to be decoded without a prefix")
+	}
+}
+
+func TestWithNaming(t *testing.T) {
+	os.Setenv("http-server-port", "9090")
+	os.Setenv("http_server_port", "9191")
+	defer os.Unsetenv("http-server-port")
+	defer os.Unsetenv("http_server_port")
+
+	var tc struct {
+		HTTPServer struct {
+			Port int `env:"PORT"`
+		}
+	}
+
+	tests := []struct {
+		naming   NamingStrategy
+		expected int
+	}{
+		{KebabCase, 9090},
+		{SnakeCase, 9191},
+		{func(words []string) string { return "http-server-port" }, 9090},
+	}
+
+	for _, test := range tests {
+		tc.HTTPServer.Port = 0
+		if err := DecodeWithOptions(&tc, WithNaming(test.naming)); err != nil {
+			t.Fatal(err)
+		}
+		if tc.HTTPServer.Port != test.expected {
+			t.Fatalf("Expected %d, got %d", test.expected, tc.HTTPServer.Port)
+		}
+	}
+}
+
+func TestWithPrivateSecretFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not checked on Windows")
+	}
+
+	dir, err := ioutil.TempDir("", "envdecode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secret := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(secret, []byte("hunter2"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("TEST_SECRET_FILE", secret)
+	os.Setenv("TEST_PUBLIC_FILE", secret)
+	defer os.Unsetenv("TEST_SECRET_FILE")
+	defer os.Unsetenv("TEST_PUBLIC_FILE")
+
+	var tc struct {
+		Secret string `env:"TEST_SECRET_FILE,loadfile,secret"`
+		Public string `env:"TEST_PUBLIC_FILE,loadfile"`
+	}
+
+	for _, mode := range []os.FileMode{0600, 0640, 0400} {
+		if err := os.Chmod(secret, mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := DecodeWithOptions(&tc, WithPrivateSecretFiles()); err != nil {
+			t.Fatalf("Expected mode %v to be accepted, got %v", mode, err)
+		}
+		if tc.Secret != "hunter2" {
+			t.Fatalf(`Expected "hunter2", got %q`, tc.Secret)
+		}
+	}
+
+	if err := os.Chmod(secret, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecodeWithOptions(&tc, WithPrivateSecretFiles()); err == nil {
+		t.Fatal("Expected an error loading a world-readable secret file")
+	}
+	if err := Decode(&tc); err != nil {
+		t.Fatalf("Expected no check without WithPrivateSecretFiles, got %v", err)
+	}
+}
+
+type testConfigEncrypted struct {
+	Password string `env:"TEST_ENCRYPTED_PASSWORD,encrypted"`
+	Port     int    `env:"TEST_ENCRYPTED_PORT,encrypted,strict"`
+	Key      []byte `env:"TEST_ENCRYPTED_KEY,encrypted,loadfile"`
+	Plain    string `env:"TEST_ENCRYPTED_PLAIN"`
+}
+
+func TestWithDecryptor(t *testing.T) {
+	block, err := aes.NewCipher([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	encrypt := func(plaintext string) []byte {
+		return gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	}
+	decrypt := func(ciphertext []byte) ([]byte, error) {
+		n := gcm.NonceSize()
+		if len(ciphertext) < n {
+			return nil, errors.New("ciphertext too short")
+		}
+		return gcm.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+	}
+
+	dir, err := ioutil.TempDir("", "envdecode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "key")
+	if err := ioutil.WriteFile(keyFile, encrypt("\x00binary\x01"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("TEST_ENCRYPTED_PASSWORD", base64.StdEncoding.EncodeToString(encrypt("hunter2")))
+	os.Setenv("TEST_ENCRYPTED_PORT", base64.StdEncoding.EncodeToString(encrypt("8080")))
+	os.Setenv("TEST_ENCRYPTED_KEY", keyFile)
+	os.Setenv("TEST_ENCRYPTED_PLAIN", "plain")
+	defer os.Unsetenv("TEST_ENCRYPTED_PASSWORD")
+	defer os.Unsetenv("TEST_ENCRYPTED_PORT")
+	defer os.Unsetenv("TEST_ENCRYPTED_KEY")
+	defer os.Unsetenv("TEST_ENCRYPTED_PLAIN")
+
+	var tc testConfigEncrypted
+	if err := DecodeWithOptions(&tc, WithDecryptor(decrypt)); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Password != "hunter2" {
+		t.Fatalf(`Expected "hunter2", got %q`, tc.Password)
+	}
+	if tc.Port != 8080 {
+		t.Fatalf("Expected 8080, got %d", tc.Port)
+	}
+	if string(tc.Key) != "\x00binary\x01" {
+		t.Fatalf("Expected decrypted file contents, got %q", tc.Key)
+	}
+	if tc.Plain != "plain" {
+		t.Fatalf(`Expected "plain", got %q`, tc.Plain)
+	}
+
+	if err := Decode(&tc); err == nil {
+		t.Fatal("Expected an error decoding encrypted fields without a decryptor")
+	}
+
+	os.Setenv("TEST_ENCRYPTED_PASSWORD", base64.StdEncoding.EncodeToString([]byte("not encrypted")))
+	if err := DecodeWithOptions(&tc, WithDecryptor(decrypt)); err == nil {
+		t.Fatal("Expected an error decrypting an invalid ciphertext")
+	}
+}
+
+func TestLenientBools(t *testing.T) {
+	var tc struct {
+		Lenient bool   `env:"TEST_LENIENT_BOOL,lenient,strict"`
+		Bools   []bool `env:"TEST_LENIENT_BOOLS,strict"`
+	}
+
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{"yes", true},
+		{"ON", true},
+		{"Enabled", true},
+		{"y", true},
+		{"true", true},
+		{"1", true},
+		{"no", false},
+		{"off", false},
+		{"DISABLED", false},
+		{"0", false},
+	}
+
+	defer os.Unsetenv("TEST_LENIENT_BOOL")
+	defer os.Unsetenv("TEST_LENIENT_BOOLS")
+	for _, test := range tests {
+		tc.Lenient = !test.expected
+		os.Setenv("TEST_LENIENT_BOOL", test.value)
+		if err := Decode(&tc); err != nil {
+			t.Fatalf("Decoding %q: %v", test.value, err)
+		}
+		if tc.Lenient != test.expected {
+			t.Fatalf("Expected %q to decode as %v", test.value, test.expected)
+		}
+	}
+
+	os.Setenv("TEST_LENIENT_BOOL", "maybe")
+	if err := Decode(&tc); err == nil {
+		t.Fatal(`Expected an error decoding "maybe"`)
+	}
+	os.Unsetenv("TEST_LENIENT_BOOL")
+
+	os.Setenv("TEST_LENIENT_BOOLS", "on;off;yes")
+	if err := Decode(&tc); err == nil {
+		t.Fatal("Expected an error decoding lenient bools without the option")
+	}
+	if err := DecodeWithOptions(&tc, WithLenientBools()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tc.Bools, []bool{true, false, true}) {
+		t.Fatalf("Unexpected bools %v", tc.Bools)
+	}
+}
+
+type testConfigOnce struct {
+	Name string `env:"TEST_ONCE_NAME"`
+	Port int    `env:"TEST_ONCE_PORT,default=8080"`
+}
+
+func TestOnce(t *testing.T) {
+	os.Setenv("TEST_ONCE_NAME", "first")
+	defer os.Unsetenv("TEST_ONCE_NAME")
+
+	config := Once[testConfigOnce]()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := config(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	os.Setenv("TEST_ONCE_NAME", "second")
+	cfg, err := config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg != (testConfigOnce{Name: "first", Port: 8080}) {
+		t.Fatalf("Expected the first decode to be cached, got %+v", cfg)
+	}
+
+	failing := Once[testConfigOnce](WithDecoder(func(s string) (int, error) {
+		return 0, errors.New("bad port")
+	}))
+	os.Setenv("TEST_ONCE_PORT", "1")
+	defer os.Unsetenv("TEST_ONCE_PORT")
+	if _, err := failing(); err == nil {
+		t.Fatal("Expected an error")
+	}
+	os.Unsetenv("TEST_ONCE_PORT")
+	if _, err := failing(); err == nil {
+		t.Fatal("Expected the error to be cached")
+	}
+}
+
+func TestWithWindowsExpansion(t *testing.T) {
+	os.Setenv("TEST_WINEXP_PROGRAMDATA", `C:\ProgramData`)
+	os.Setenv("TEST_WINEXP_LOG_DIR", `%TEST_WINEXP_PROGRAMDATA%\app\logs`)
+	os.Setenv("TEST_WINEXP_MESSAGE", `100%% of %TEST_WINEXP_UNSET% in 50%-75%TEST_WINEXP_PROGRAMDATA%`)
+	defer os.Unsetenv("TEST_WINEXP_PROGRAMDATA")
+	defer os.Unsetenv("TEST_WINEXP_LOG_DIR")
+	defer os.Unsetenv("TEST_WINEXP_MESSAGE")
+
+	var tc struct {
+		LogDir  string `env:"TEST_WINEXP_LOG_DIR"`
+		Message string `env:"TEST_WINEXP_MESSAGE"`
+		Cache   string `env:"TEST_WINEXP_CACHE,default=%TEST_WINEXP_PROGRAMDATA%\\cache"`
+	}
+	if err := DecodeWithOptions(&tc, WithWindowsExpansion()); err != nil {
+		t.Fatal(err)
+	}
+	if tc.LogDir != `C:\ProgramData\app\logs` {
+		t.Fatalf("Unexpected LogDir %q", tc.LogDir)
+	}
+	if tc.Message != `100% of %TEST_WINEXP_UNSET% in 50%-75C:\ProgramData` {
+		t.Fatalf("Unexpected Message %q", tc.Message)
+	}
+	if tc.Cache != `C:\ProgramData\cache` {
+		t.Fatalf("Unexpected Cache %q", tc.Cache)
+	}
+
+	if err := DecodeWithOptions(&tc); err != nil {
+		t.Fatal(err)
+	}
+	if tc.LogDir != `%TEST_WINEXP_PROGRAMDATA%\app\logs` {
+		t.Fatalf("Expected no expansion without the option, got %q", tc.LogDir)
+	}
+}
+
+func TestWithYAML(t *testing.T) {
+	os.Setenv("TEST_DOC_EXTRA", `[{"name":"worker","port":7070}]`)
+	defer os.Unsetenv("TEST_DOC_EXTRA")
+
+	var tc testConfigDocuments
+	if err := Decode(&tc); err == nil || !strings.Contains(err.Error(), "WithYAML") {
+		t.Fatalf("Expected an error without WithYAML, got %v", err)
+	}
+
+	// JSON is a subset of YAML, which is enough to check the plumbing.
+	var got []byte
+	yaml := func(data []byte, v interface{}) error {
+		got = data
+		return json.Unmarshal(data, v)
+	}
+	if err := DecodeWithOptions(&tc, WithYAML(yaml)); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `[{"name":"worker","port":7070}]` {
+		t.Fatalf("Unexpected document %q", got)
+	}
+	if len(tc.Extra) != 1 || tc.Extra[0] != (testService{"worker", 7070}) {
+		t.Fatalf("Unexpected services %+v", tc.Extra)
+	}
+}
+
+type panickingDecoder struct{}
+
+func (panickingDecoder) Decode(string) error {
+	var m map[string]int
+	m["boom"]++
+	return nil
+}
+
+func TestWithNoPanics(t *testing.T) {
+	os.Setenv("TEST_NOPANIC", "value")
+	defer os.Unsetenv("TEST_NOPANIC")
+
+	var rd struct {
+		Value string `env:"TEST_NOPANIC,required,default=x"`
+	}
+	err := DecodeWithOptions(&rd, WithNoPanics())
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Field != "Value" || !strings.Contains(err.Error(), `"default" and "required"`) {
+		t.Fatalf("Expected an error for required and default, got %v", err)
+	}
+
+	var pd struct {
+		Name  string           `env:"TEST_NOPANIC"`
+		Value panickingDecoder `env:"TEST_NOPANIC"`
+	}
+	err = DecodeWithOptions(&pd, WithNoPanics())
+	if !errors.As(err, &de) || de.Field != "Value" || !strings.Contains(err.Error(), "panic while decoding: assignment to entry in nil map") {
+		t.Fatalf("Expected the panic to become an error, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected a panic without WithNoPanics")
+		}
+	}()
+	DecodeWithOptions(&pd)
+}
+
+type testCycleNode struct {
+	Name string `env:"TEST_CYCLE_NAME"`
+	Next *testCycleNode
+}
+
+type testDepth struct {
+	A struct {
+		B struct {
+			C struct {
+				Name string `env:"TEST_CYCLE_NAME"`
+			}
+		}
+	}
+}
+
+func TestCycles(t *testing.T) {
+	os.Setenv("TEST_CYCLE_NAME", "node")
+	defer os.Unsetenv("TEST_CYCLE_NAME")
+
+	var n testCycleNode
+	if err := Decode(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n.Name != "node" || n.Next != nil {
+		t.Fatalf("Expected the self-referential pointer to stay nil, got %+v", n)
+	}
+
+	n.Next = &testCycleNode{}
+	n.Next.Next = &n
+	err := Decode(&n)
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Field != "Next.Next" || !strings.Contains(err.Error(), "pointer cycle") {
+		t.Fatalf("Expected a cycle error, got %v", err)
+	}
+	if _, err := Export(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	var d testDepth
+	if err := DecodeWithOptions(&d, WithMaxDepth(3)); err != nil {
+		t.Fatal(err)
+	}
+	err = DecodeWithOptions(&d, WithMaxDepth(2))
+	if !errors.As(err, &de) || de.Field != "A.B.C" || !strings.Contains(err.Error(), "nested more than 2 deep") {
+		t.Fatalf("Expected a depth error, got %v", err)
+	}
+}
+
+type testConfigSliceSeparator struct {
+	Hosts  []string       `env:"TEST_SEPARATOR_HOSTS"`
+	Ports  []int          `env:"TEST_SEPARATOR_PORTS,default=80\\,443"`
+	Limits map[string]int `env:"TEST_SEPARATOR_LIMITS"`
+	Users  []testCSVUser  `env:"TEST_SEPARATOR_USERS,csv"`
+}
+
+func TestWithSliceSeparator(t *testing.T) {
+	os.Setenv("TEST_SEPARATOR_HOSTS", "a.example.com, b.example.com")
+	os.Setenv("TEST_SEPARATOR_LIMITS", "cpu:2,memory:512")
+	os.Setenv("TEST_SEPARATOR_USERS", "alice,true,1;bob,false,2")
+	defer os.Unsetenv("TEST_SEPARATOR_HOSTS")
+	defer os.Unsetenv("TEST_SEPARATOR_LIMITS")
+	defer os.Unsetenv("TEST_SEPARATOR_USERS")
+
+	var tc testConfigSliceSeparator
+	if err := DecodeWithOptions(&tc, WithSliceSeparator(",")); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tc.Hosts, []string{"a.example.com", "b.example.com"}) {
+		t.Fatalf("Unexpected hosts %q", tc.Hosts)
+	}
+	if !reflect.DeepEqual(tc.Ports, []int{80, 443}) {
+		t.Fatalf("Unexpected ports %v", tc.Ports)
+	}
+	if !reflect.DeepEqual(tc.Limits, map[string]int{"cpu": 2, "memory": 512}) {
+		t.Fatalf("Unexpected limits %v", tc.Limits)
+	}
+	if len(tc.Users) != 2 || tc.Users[1].Name != "bob" {
+		t.Fatalf("Unexpected users %+v", tc.Users)
+	}
+	if err := ValidateStruct(&tc, WithSliceSeparator(",")); err != nil {
+		t.Fatal(err)
+	}
+}
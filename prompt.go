@@ -0,0 +1,85 @@
+package envdecode
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// promptInput and promptOutput are replaced by tests of WithPrompt.
+var (
+	promptInput  io.Reader = os.Stdin
+	promptOutput io.Writer = os.Stderr
+)
+
+// WithPrompt asks for the values of required variables that are not
+// set, instead of failing, for interactive command-line tools.  Each
+// prompt names the variable, with its description, on standard error,
+// and the answer is read as a line from standard input.  Secrets are
+// read with readSecret so that they aren't echoed, such as
+//
+//	func() ([]byte, error) { return term.ReadPassword(int(os.Stdin.Fd())) }
+//
+// using golang.org/x/term.  If readSecret is nil, secrets are not
+// prompted for and remain missing.  An empty answer is treated as a
+// missing value.  Answers are described as "prompt".
+func WithPrompt(readSecret func() ([]byte, error)) Option {
+	return func(o *options) {
+		o.prompt = true
+		o.readSecret = readSecret
+	}
+}
+
+// promptFor asks for the value of the variable read by a field with
+// opts, returning "" if none is given.
+func (d *decodeState) promptFor(opts tagOptions) (string, error) {
+	if opts.secret && d.readSecret == nil {
+		return "", nil
+	}
+
+	msg := opts.name
+	if opts.description != "" {
+		msg += " (" + opts.description + ")"
+	}
+	fmt.Fprintf(promptOutput, "%s: ", msg)
+
+	if opts.secret {
+		b, err := d.readSecret()
+		// The terminal doesn't echo the newline either.
+		fmt.Fprintln(promptOutput)
+		if err != nil {
+			return "", fmt.Errorf("envdecode: reading \"%s\": %v", opts.name, err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+
+	line, err := readLine(promptInput)
+	if err != nil {
+		return "", fmt.Errorf("envdecode: reading \"%s\": %v", opts.name, err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// readLine reads a line from r a byte at a time, so that nothing is
+// read beyond it for a later prompt or readSecret to miss.  EOF ends
+// the line.
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				return string(line), nil
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF {
+			return string(line), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
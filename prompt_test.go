@@ -0,0 +1,56 @@
+package envdecode
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+type testConfigPrompt struct {
+	Host     string `env:"TEST_PROMPT_HOST,required,desc=Server host"`
+	Port     int    `env:"TEST_PROMPT_PORT,required"`
+	User     string `env:"TEST_PROMPT_USER,default=admin"`
+	Password string `env:"TEST_PROMPT_PASSWORD,required,secret"`
+}
+
+func TestWithPrompt(t *testing.T) {
+	defer func(r io.Reader, w io.Writer) { promptInput, promptOutput = r, w }(promptInput, promptOutput)
+
+	os.Setenv("TEST_PROMPT_PORT", "8080")
+	defer os.Unsetenv("TEST_PROMPT_PORT")
+
+	var out bytes.Buffer
+	promptInput = strings.NewReader("example.com\n")
+	promptOutput = &out
+
+	var tc testConfigPrompt
+	err := DecodeWithOptions(&tc, WithPrompt(func() ([]byte, error) {
+		return []byte("hunter2\n"), nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.Host != "example.com" || tc.Port != 8080 || tc.User != "admin" || tc.Password != "hunter2" {
+		t.Fatalf("Unexpected config %+v", tc)
+	}
+	if out.String() != "TEST_PROMPT_HOST (Server host): TEST_PROMPT_PASSWORD: \n" {
+		t.Fatalf("Unexpected prompts %q", out.String())
+	}
+
+	// Secrets are not read without readSecret, nor are empty answers
+	// accepted.
+	promptInput = strings.NewReader("example.com\n")
+	tc = testConfigPrompt{}
+	err = DecodeWithOptions(&tc, WithPrompt(nil))
+	if err == nil || !strings.Contains(err.Error(), "TEST_PROMPT_PASSWORD") {
+		t.Fatalf("Expected TEST_PROMPT_PASSWORD to be missing, got %v", err)
+	}
+
+	promptInput = strings.NewReader("\n")
+	err = DecodeWithOptions(&tc, WithPrompt(nil))
+	if err == nil || !strings.Contains(err.Error(), "TEST_PROMPT_HOST") {
+		t.Fatalf("Expected TEST_PROMPT_HOST to be missing, got %v", err)
+	}
+}
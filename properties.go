@@ -0,0 +1,177 @@
+package envdecode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// PropertiesSource returns a Source for the properties in the Java
+// .properties file at path, for platforms distributing configuration
+// in that format.  Keys and values may be separated by "=", ":" or
+// whitespace, lines ending in a backslash continue on the next, and
+// escapes such as \t and \u00e9 are understood.  Keys are mapped to
+// variable names by upper-casing them and replacing dots and hyphens
+// with underscores, so "db.max-conns" is read by DB_MAX_CONNS.  The
+// file is read once.  Values are described as "properties:" followed by
+// path.
+func PropertiesSource(path string) (Source, error) {
+	b, err := readFile(path, defaultMaxFileSize, false)
+	if err != nil {
+		return nil, fmt.Errorf("envdecode: reading %s: %v", path, err)
+	}
+	props, err := parseProperties(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("envdecode: parsing %s: %v", path, err)
+	}
+
+	values := make(mapSource, len(props))
+	for key, value := range props {
+		values[variableName(key)] = value
+	}
+	return fileSource{mapSource: values, desc: "properties:" + path}, nil
+}
+
+// fileSource is a Source for the values read from a configuration file,
+// described as desc.
+type fileSource struct {
+	mapSource
+	desc string
+}
+
+func (s fileSource) Describe(name string) string {
+	return s.desc
+}
+
+// variableName maps a key from a configuration file, such as
+// "db.max-conns", to the name of a variable, DB_MAX_CONNS.
+func variableName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, strings.ToUpper(key))
+}
+
+// parseProperties parses the contents of a .properties file, following
+// java.util.Properties.  A key given more than once takes its last
+// value.
+func parseProperties(s string) (map[string]string, error) {
+	props := map[string]string{}
+	lines := strings.Split(strings.Replace(s, "\r\n", "\n", -1), "\n")
+	for n := 0; n < len(lines); n++ {
+		first := n + 1
+		line := strings.TrimLeft(lines[n], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		// Join continuation lines, which end in an odd number of
+		// backslashes, dropping the leading whitespace of the next.
+		for continues(line) && n+1 < len(lines) {
+			n++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[n], " \t\f")
+		}
+		if continues(line) {
+			line = line[:len(line)-1]
+		}
+
+		key, value := splitProperty(line)
+		k, err := unescapeProperty(key)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", first, err)
+		}
+		v, err := unescapeProperty(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", first, err)
+		}
+		props[k] = v
+	}
+	return props, nil
+}
+
+// continues reports whether line ends in an unescaped backslash.
+func continues(line string) bool {
+	n := 0
+	for n < len(line) && line[len(line)-1-n] == '\\' {
+		n++
+	}
+	return n%2 == 1
+}
+
+// splitProperty splits a logical line into its still escaped key and
+// value.  The key ends at the first unescaped "=", ":" or whitespace,
+// which may be followed by whitespace and one "=" or ":".
+func splitProperty(line string) (key, value string) {
+	i := 0
+	for ; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.IndexByte("=: \t\f", line[i]) >= 0 {
+			break
+		}
+	}
+	if i >= len(line) {
+		return line, ""
+	}
+	key, rest := line[:i], strings.TrimLeft(line[i:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') && strings.IndexByte(" \t\f", line[i]) >= 0 {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	} else if line[i] == '=' || line[i] == ':' {
+		rest = strings.TrimLeft(line[i+1:], " \t\f")
+	}
+	return key, rest
+}
+
+// unescapeProperty replaces the escapes in a key or value of a
+// .properties file.
+func unescapeProperty(s string) (string, error) {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("malformed \\u escape %q", s[i-1:])
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\u escape %q", s[i-1:i+5])
+			}
+			i += 4
+			// Characters outside the Basic Multilingual Plane are
+			// written as a pair of UTF-16 surrogates.
+			if utf16.IsSurrogate(rune(r)) && i+7 <= len(s) && strings.HasPrefix(s[i+1:], `\u`) {
+				if lo, err := strconv.ParseUint(s[i+3:i+7], 16, 16); err == nil {
+					if pair := utf16.DecodeRune(rune(r), rune(lo)); pair != utf8.RuneError {
+						r = uint64(pair)
+						i += 6
+					}
+				}
+			}
+			b.WriteRune(rune(r))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
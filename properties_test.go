@@ -0,0 +1,89 @@
+package envdecode
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseProperties(t *testing.T) {
+	props, err := parseProperties("# comment\r\n" +
+		"! also a comment\n" +
+		"   \n" +
+		"db.host = db.internal\n" +
+		"db.port:5432\n" +
+		"db.user postgres\n" +
+		"greeting    =   hello \\\n" +
+		"            world\n" +
+		"path=C:\\\\data\\\\app\n" +
+		"key\\ with\\ spaces=1\n" +
+		"unicode=caf\\u00e9 \\ud83d\\ude00\n" +
+		"tabs=a\\tb\n" +
+		"empty\n" +
+		"db.port=5433\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"db.host":         "db.internal",
+		"db.port":         "5433",
+		"db.user":         "postgres",
+		"greeting":        "hello world",
+		"path":            `C:\data\app`,
+		"key with spaces": "1",
+		"unicode":         "café 😀",
+		"tabs":            "a\tb",
+		"empty":           "",
+	}
+	if !reflect.DeepEqual(props, expected) {
+		t.Fatalf("Expected %q, got %q", expected, props)
+	}
+
+	if _, err := parseProperties("a=\\u12"); err == nil {
+		t.Fatal("Expected an error for a malformed \\u escape")
+	}
+	if _, err := parseProperties("a=\\uzzzz"); err == nil {
+		t.Fatal("Expected an error for a malformed \\u escape")
+	}
+}
+
+func TestPropertiesSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envdecode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.properties")
+	if err := ioutil.WriteFile(path, []byte("db.host=db.internal\ndb.max-conns=20\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	src, err := PropertiesSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tc struct {
+		Host     string `env:"DB_HOST"`
+		MaxConns int    `env:"DB_MAX_CONNS"`
+	}
+	cfg, err := Preview(&tc, WithSources(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DecodeWithOptions(&tc, WithSources(src)); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Host != "db.internal" || tc.MaxConns != 20 {
+		t.Fatalf("Unexpected config %+v", tc)
+	}
+	if cfg[0].Source != "properties:"+path {
+		t.Fatalf("Expected the value to come from the file, got %q", cfg[0].Source)
+	}
+
+	if _, err := PropertiesSource(filepath.Join(dir, "missing.properties")); err == nil {
+		t.Fatal("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,51 @@
+package envdecode
+
+import (
+	"fmt"
+	"path"
+)
+
+// DefaultRedactions are patterns for WithRedaction matching the usual
+// names of variables holding credentials.
+var DefaultRedactions = []string{
+	"*_TOKEN",
+	"*_PASSWORD",
+	"*_PASSWD",
+	"*_SECRET",
+	"*_API_KEY",
+	"*_PRIVATE_KEY",
+	"*_CREDENTIALS",
+}
+
+// WithRedaction treats the variables whose names match any of
+// patterns, in the syntax of path.Match, as if their fields were
+// tagged ",secret" when reporting values, as a defense against leaking
+// credentials whose fields weren't tagged.  It affects Export,
+// ExportGroups, ExportJSON, Handler, PublishExpvar, Preview and
+// CaptureSnapshot, but not decoding itself.  DefaultRedactions holds
+// common patterns:
+//
+//	envdecode.Handler(&cfg, envdecode.WithRedaction(envdecode.DefaultRedactions...))
+//
+// It panics if a pattern is malformed.
+func WithRedaction(patterns ...string) Option {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			panic(fmt.Sprintf("envdecode: bad redaction pattern %q", p))
+		}
+	}
+	return func(o *options) {
+		o.redactions = append(o.redactions, patterns...)
+	}
+}
+
+// redacts reports whether the variable name matches a pattern given
+// with WithRedaction.
+func (o *options) redacts(name string) bool {
+	for _, p := range o.redactions {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package envdecode
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+type testConfigRedact struct {
+	Host     string `env:"TEST_REDACT_HOST"`
+	APIToken string `env:"TEST_REDACT_API_TOKEN"`
+	Password string `env:"TEST_REDACT_DB_PASSWORD"`
+}
+
+func TestWithRedaction(t *testing.T) {
+	tc := testConfigRedact{Host: "db.internal", APIToken: "abc123", Password: "hunter2"}
+
+	cfg, err := Export(&tc, WithRedaction(DefaultRedactions...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]string{}
+	for _, ci := range cfg {
+		values[ci.EnvVar] = ci.Value
+		if ci.Secret != (ci.EnvVar != "TEST_REDACT_HOST") {
+			t.Fatalf("Expected only TEST_REDACT_HOST not to be secret, got %+v", ci)
+		}
+	}
+	if values["TEST_REDACT_HOST"] != "db.internal" || values["TEST_REDACT_API_TOKEN"] != redactedValue || values["TEST_REDACT_DB_PASSWORD"] != redactedValue {
+		t.Fatalf("Expected the token and password to be redacted, got %v", values)
+	}
+
+	b, err := ExportJSON(&tc, WithRedaction("TEST_REDACT_*_TOKEN"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "abc123") || !strings.Contains(string(b), "hunter2") {
+		t.Fatalf("Expected only the token to be redacted, got %s", b)
+	}
+
+	os.Setenv("TEST_REDACT_HOST", "db.internal")
+	os.Setenv("TEST_REDACT_API_TOKEN", "abc123")
+	defer os.Unsetenv("TEST_REDACT_HOST")
+	defer os.Unsetenv("TEST_REDACT_API_TOKEN")
+
+	cfg, err = Preview(&tc, WithRedaction(DefaultRedactions...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ci := range cfg {
+		if ci.EnvVar == "TEST_REDACT_API_TOKEN" && ci.Value != redactedValue {
+			t.Fatalf("Expected the token to be redacted, got %+v", ci)
+		}
+	}
+
+	s, err := CaptureSnapshot(&tc, WithRedaction(DefaultRedactions...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Values["TEST_REDACT_API_TOKEN"]; ok || len(s.Redacted) != 1 || s.Redacted[0] != "TEST_REDACT_API_TOKEN" {
+		t.Fatalf("Expected the token to be redacted, got %+v", s)
+	}
+
+	// Decoding is unaffected.
+	var decoded testConfigRedact
+	if err := DecodeWithOptions(&decoded, WithRedaction(DefaultRedactions...)); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.APIToken != "abc123" {
+		t.Fatalf("Expected the token to be decoded, got %q", decoded.APIToken)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected a malformed pattern to panic")
+		}
+	}()
+	WithRedaction("[")
+}
@@ -0,0 +1,44 @@
+package envdecode
+
+import "sort"
+
+// WithRenames reads variables that have been renamed under their old
+// names too, for a migration across releases.  renames maps old names
+// to new ones, such as {"DB_URL": "DATABASE_URL"}; when the new name is
+// not set, the old names mapped to it are looked up in sorted order,
+// and a warning is raised if one of them is used.  Renames given by
+// several WithRenames are combined.
+func WithRenames(renames map[string]string) Option {
+	return func(o *options) {
+		if o.renamed == nil {
+			o.renamed = map[string][]string{}
+		}
+		for old, name := range renames {
+			o.renamed[name] = append(o.renamed[name], old)
+			sort.Strings(o.renamed[name])
+		}
+	}
+}
+
+// WithStrictRenames fails the decode when a variable is found under an
+// old name given to WithRenames, rather than raising a warning, to
+// enforce that a migration is complete, such as in staging before the
+// old names are dropped in production.
+func WithStrictRenames() Option {
+	return func(o *options) {
+		o.strictRenames = true
+	}
+}
+
+// lookupRenamed looks up the old names of the variable read by a field
+// with opts, returning the value of the first one set, its source and
+// its name.
+func (d *decodeState) lookupRenamed(opts tagOptions) (string, string, string, error) {
+	for _, old := range d.renamed[opts.name] {
+		env, source, err := d.lookup(old)
+		if err != nil || env != "" {
+			return env, source, old, err
+		}
+	}
+	return "", "", "", nil
+}
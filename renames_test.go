@@ -0,0 +1,66 @@
+package envdecode
+
+import (
+	"os"
+	"testing"
+)
+
+type testConfigRenames struct {
+	URL  string `env:"TEST_RENAMES_DATABASE_URL,required"`
+	Pool int    `env:"TEST_RENAMES_POOL,default=4"`
+}
+
+func TestWithRenames(t *testing.T) {
+	os.Setenv("TEST_RENAMES_DB_URL", "postgres://old")
+	defer os.Unsetenv("TEST_RENAMES_DB_URL")
+	os.Setenv("TEST_RENAMES_POOL_SIZE", "8")
+	defer os.Unsetenv("TEST_RENAMES_POOL_SIZE")
+
+	var warnings []Warning
+	var tc testConfigRenames
+	err := DecodeWithOptions(&tc,
+		WithRenames(map[string]string{"TEST_RENAMES_DB_URL": "TEST_RENAMES_DATABASE_URL"}),
+		WithRenames(map[string]string{"TEST_RENAMES_POOL_SIZE": "TEST_RENAMES_POOL"}),
+		WithWarnings(func(w Warning) { warnings = append(warnings, w) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.URL != "postgres://old" || tc.Pool != 8 {
+		t.Fatalf("Unexpected config %+v", tc)
+	}
+	if len(warnings) != 2 || warnings[0].Field != "URL" || warnings[0].Message != "TEST_RENAMES_DB_URL is deprecated; use TEST_RENAMES_DATABASE_URL instead" {
+		t.Fatalf("Unexpected warnings %v", warnings)
+	}
+
+	// The new name takes precedence.
+	os.Setenv("TEST_RENAMES_DATABASE_URL", "postgres://new")
+	defer os.Unsetenv("TEST_RENAMES_DATABASE_URL")
+	warnings = nil
+	err = DecodeWithOptions(&tc,
+		WithRenames(map[string]string{"TEST_RENAMES_DB_URL": "TEST_RENAMES_DATABASE_URL"}),
+		WithWarnings(func(w Warning) { warnings = append(warnings, w) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.URL != "postgres://new" || len(warnings) != 0 {
+		t.Fatalf("Unexpected config %+v, warnings %v", tc, warnings)
+	}
+}
+
+func TestWithStrictRenames(t *testing.T) {
+	os.Setenv("TEST_RENAMES_DB_URL", "postgres://old")
+	defer os.Unsetenv("TEST_RENAMES_DB_URL")
+
+	renames := WithRenames(map[string]string{"TEST_RENAMES_DB_URL": "TEST_RENAMES_DATABASE_URL"})
+	var tc testConfigRenames
+	err := DecodeWithOptions(&tc, renames, WithStrictRenames())
+	if err == nil || err.Error() != `envdecode: "TEST_RENAMES_DB_URL" is deprecated; use "TEST_RENAMES_DATABASE_URL" instead` {
+		t.Fatalf("Expected an error for the old name, got %v", err)
+	}
+
+	os.Setenv("TEST_RENAMES_DATABASE_URL", "postgres://new")
+	defer os.Unsetenv("TEST_RENAMES_DATABASE_URL")
+	if err := DecodeWithOptions(&tc, renames, WithStrictRenames()); err != nil || tc.URL != "postgres://new" {
+		t.Fatalf("Unexpected config %+v, %v", tc, err)
+	}
+}
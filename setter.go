@@ -0,0 +1,41 @@
+package envdecode
+
+import (
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// setter returns the setter method of the unexported field sf of the
+// struct type t: a method of *t named "Set" followed by the field's
+// name with its first letter capitalized, such as SetTimeout for
+// timeout, taking a single argument and returning nothing or an error.
+func setter(t reflect.Type, sf reflect.StructField) (reflect.Method, bool) {
+	if sf.PkgPath == "" || sf.Anonymous {
+		return reflect.Method{}, false
+	}
+	r, n := utf8.DecodeRuneInString(sf.Name)
+	m, ok := reflect.PtrTo(t).MethodByName("Set" + string(unicode.ToUpper(r)) + sf.Name[n:])
+	if !ok || m.Type.NumIn() != 2 {
+		return reflect.Method{}, false
+	}
+	switch m.Type.NumOut() {
+	case 0:
+		return m, true
+	case 1:
+		return m, m.Type.Out(0) == errorType
+	}
+	return reflect.Method{}, false
+}
+
+// callSetter passes v to the setter method m of the addressable struct
+// s, returning its error, if any.
+func callSetter(s reflect.Value, m reflect.Method, v reflect.Value) error {
+	out := s.Addr().Method(m.Index).Call([]reflect.Value{v})
+	if len(out) == 1 && !out[0].IsNil() {
+		return out[0].Interface().(error)
+	}
+	return nil
+}
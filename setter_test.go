@@ -0,0 +1,59 @@
+package envdecode
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfigSetter struct {
+	Name    string        `env:"TEST_SETTER_NAME"`
+	timeout time.Duration `env:"TEST_SETTER_TIMEOUT,default=5s"`
+	workers int           `env:"TEST_SETTER_WORKERS"`
+	hidden  string        `env:"TEST_SETTER_HIDDEN"`
+}
+
+func (c *testConfigSetter) SetTimeout(d time.Duration) error {
+	if d <= 0 {
+		return errors.New("timeout must be positive")
+	}
+	c.timeout = d
+	return nil
+}
+
+func (c *testConfigSetter) SetWorkers(n int) {
+	c.workers = n
+}
+
+func TestDecodeSetters(t *testing.T) {
+	os.Setenv("TEST_SETTER_WORKERS", "8")
+	os.Setenv("TEST_SETTER_HIDDEN", "ignored")
+	defer os.Unsetenv("TEST_SETTER_WORKERS")
+	defer os.Unsetenv("TEST_SETTER_HIDDEN")
+
+	var tc testConfigSetter
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+	if tc.timeout != 5*time.Second || tc.workers != 8 || tc.hidden != "" {
+		t.Fatalf("Unexpected config %+v", tc)
+	}
+
+	os.Setenv("TEST_SETTER_TIMEOUT", "-1s")
+	defer os.Unsetenv("TEST_SETTER_TIMEOUT")
+	err := Decode(&tc)
+	if err == nil || !strings.Contains(err.Error(), "timeout must be positive") {
+		t.Fatalf("Expected the setter's error, got %v", err)
+	}
+
+	names, err := Variables(&tc)
+	if err != nil || strings.Join(names, " ") != "TEST_SETTER_NAME TEST_SETTER_TIMEOUT TEST_SETTER_WORKERS" {
+		t.Fatalf("Unexpected variables %v, %v", names, err)
+	}
+	cfg, err := Export(&tc)
+	if err != nil || len(cfg) != 3 || cfg[1].Field != "timeout" || cfg[1].DefaultValue != "5s" {
+		t.Fatalf("Unexpected export %+v, %v", cfg, err)
+	}
+}
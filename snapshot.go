@@ -0,0 +1,82 @@
+package envdecode
+
+import "os"
+
+// An EnvSnapshot holds the variables read when decoding a target, so
+// that a failing startup can be reproduced elsewhere, such as from a
+// support bundle.  It marshals to JSON as is.  The values of the
+// variables read by secret fields, including those their default
+// chains and templates refer to, and of variables matching the patterns
+// of WithRedaction, are left out and their names listed in Redacted
+// instead.
+type EnvSnapshot struct {
+	Values   map[string]string `json:"values"`
+	Redacted []string          `json:"redacted,omitempty"`
+}
+
+// CaptureSnapshot records the values of the variables target reads, as
+// DecodeWithOptions would with opts, without modifying the target.
+// Unset variables are not recorded.  If decoding fails, the snapshot is
+// returned along with a DecodeErrors listing every field that fails, so
+// that the failure can be captured too.
+func CaptureSnapshot(target interface{}, opts ...Option) (*EnvSnapshot, error) {
+	d := newDecodeState(opts)
+	d.dryRun = true
+	d.collectErrors = true
+	d.recorded = map[string]string{}
+	d.secretNames = map[string]bool{}
+
+	_, err := d.decode(target, false)
+	if err == nil && len(d.errors) > 0 {
+		err = d.errors
+	}
+
+	// Every variable a secret field read is redacted, even one that
+	// only supplied its default.
+	s := &EnvSnapshot{Values: d.recorded}
+	for name := range s.Values {
+		if d.secretNames[name] || d.redacts(name) {
+			delete(s.Values, name)
+			s.Redacted = append(s.Redacted, name)
+		}
+	}
+	s.Redacted = uniqueStrings(s.Redacted)
+	if len(s.Redacted) == 0 {
+		s.Redacted = nil
+	}
+	return s, err
+}
+
+// Lookup returns the recorded value of name.  Redacted variables are
+// read from the environment instead, so that stand-in secrets can be
+// supplied when reproducing a decode.
+func (s *EnvSnapshot) Lookup(name string) (string, bool, error) {
+	for _, r := range s.Redacted {
+		if r == name {
+			v, ok := os.LookupEnv(name)
+			return v, ok, nil
+		}
+	}
+	v, ok := s.Values[name]
+	return v, ok, nil
+}
+
+// Names returns the names of the recorded and redacted variables.
+func (s *EnvSnapshot) Names() ([]string, error) {
+	names := append([]string{}, s.Redacted...)
+	for name := range s.Values {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// Describe describes values as "snapshot".
+func (s *EnvSnapshot) Describe(name string) string {
+	return "snapshot"
+}
+
+// DecodeSnapshot decodes target as DecodeWithOptions would, reading
+// variables from s rather than the environment.
+func DecodeSnapshot(target interface{}, s *EnvSnapshot, opts ...Option) error {
+	return DecodeWithOptions(target, append(opts, WithSources(s))...)
+}
@@ -0,0 +1,97 @@
+package envdecode
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+type testConfigSnapshot struct {
+	Host     string `env:"TEST_SNAPSHOT_HOST,required"`
+	Port     int    `env:"TEST_SNAPSHOT_PORT,default=8080"`
+	Password string `env:"TEST_SNAPSHOT_PASSWORD,secret"`
+	Debug    bool   `env:"TEST_SNAPSHOT_DEBUG"`
+	Region   string `env:"TEST_SNAPSHOT_REGION,required"`
+}
+
+func TestCaptureSnapshot(t *testing.T) {
+	os.Setenv("TEST_SNAPSHOT_HOST", "db.internal")
+	os.Setenv("TEST_SNAPSHOT_PASSWORD", "hunter2")
+	os.Setenv("TEST_SNAPSHOT_DEBUG", "true")
+	defer os.Unsetenv("TEST_SNAPSHOT_HOST")
+	defer os.Unsetenv("TEST_SNAPSHOT_PASSWORD")
+	defer os.Unsetenv("TEST_SNAPSHOT_DEBUG")
+
+	var tc testConfigSnapshot
+	s, err := CaptureSnapshot(&tc)
+	var errs DecodeErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].EnvVar != "TEST_SNAPSHOT_REGION" {
+		t.Fatalf("Expected an error for TEST_SNAPSHOT_REGION, got %v", err)
+	}
+	if tc != (testConfigSnapshot{}) {
+		t.Fatalf("Expected the target to be untouched, got %+v", tc)
+	}
+
+	expected := &EnvSnapshot{
+		Values:   map[string]string{"TEST_SNAPSHOT_HOST": "db.internal", "TEST_SNAPSHOT_DEBUG": "true"},
+		Redacted: []string{"TEST_SNAPSHOT_PASSWORD"},
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, s)
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored EnvSnapshot
+	if err := json.Unmarshal(b, &restored); err != nil {
+		t.Fatal(err)
+	}
+
+	// Elsewhere, with a stand-in secret and without the rest.
+	os.Unsetenv("TEST_SNAPSHOT_HOST")
+	os.Unsetenv("TEST_SNAPSHOT_DEBUG")
+	os.Setenv("TEST_SNAPSHOT_PASSWORD", "stand-in")
+	err = DecodeSnapshot(&tc, &restored)
+	var de *DecodeError
+	if !errors.As(err, &de) || de.EnvVar != "TEST_SNAPSHOT_REGION" {
+		t.Fatalf("Expected the failure to be reproduced, got %v", err)
+	}
+
+	restored.Values["TEST_SNAPSHOT_REGION"] = "eu-west-1"
+	if err := DecodeSnapshot(&tc, &restored); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Host != "db.internal" || tc.Port != 8080 || tc.Password != "stand-in" || !tc.Debug || tc.Region != "eu-west-1" {
+		t.Fatalf("Expected the snapshot's values, got %+v", tc)
+	}
+}
+
+func TestCaptureSnapshotSecretReferences(t *testing.T) {
+	env := []string{
+		"TEST_SNAPSHOT_LEGACY_PASSWORD=hunter2",
+		"TEST_SNAPSHOT_RAW_TOKEN=tok-123",
+		"TEST_SNAPSHOT_NAME=app",
+	}
+
+	var tc struct {
+		Password string `env:"TEST_SNAPSHOT_DB_PASSWORD,secret,default=$TEST_SNAPSHOT_LEGACY_PASSWORD"`
+		Token    string `envTemplate:"Bearer {{env \"TEST_SNAPSHOT_RAW_TOKEN\"}}" env:",secret"`
+		Name     string `env:"TEST_SNAPSHOT_NAME"`
+	}
+	s, err := CaptureSnapshot(&tc, WithSources(EnvironSource(env)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &EnvSnapshot{
+		Values:   map[string]string{"TEST_SNAPSHOT_NAME": "app"},
+		Redacted: []string{"TEST_SNAPSHOT_LEGACY_PASSWORD", "TEST_SNAPSHOT_RAW_TOKEN"},
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, s)
+	}
+}
@@ -0,0 +1,85 @@
+package envdecode
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// sopsCommand is the sops executable run by SOPSSource.
+var sopsCommand = "sops"
+
+// SOPSSource returns a Source for the variables in the SOPS encrypted
+// file at path, which may be in any format sops understands, such as
+// dotenv or flat YAML.  The file is decrypted once, by running
+// "sops --decrypt", which must be in the PATH and have access to the
+// keys, so encrypted configuration can be kept in version control and
+// decoded through the same struct.  Values are described as "sops:"
+// followed by path.
+func SOPSSource(path string) (Source, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(sopsCommand, "--decrypt", "--output-type", "dotenv", path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("envdecode: decrypting %s: %v: %s", path, err, msg)
+		}
+		return nil, fmt.Errorf("envdecode: decrypting %s: %v", path, err)
+	}
+
+	values, err := parseSOPSDotenv(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("envdecode: decrypting %s: %v", path, err)
+	}
+	return sopsSource{mapSource: values, path: path}, nil
+}
+
+// parseSOPSDotenv parses the dotenv output of sops: KEY=value lines,
+// blank lines and # comments, with newlines in values escaped as \n.
+// Values are otherwise taken literally; quotes are not removed.
+func parseSOPSDotenv(b []byte) (map[string]string, error) {
+	values := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(nil, defaultMaxFileSize)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=value", n)
+		}
+		values[line[:i]] = strings.Replace(line[i+1:], `\n`, "\n", -1)
+	}
+	return values, sc.Err()
+}
+
+// sopsSource is the Source returned by SOPSSource.
+type sopsSource struct {
+	mapSource
+	path string
+}
+
+func (s sopsSource) Describe(name string) string {
+	return "sops:" + s.path
+}
+
+// mapSource is a Source for a fixed set of values.
+type mapSource map[string]string
+
+func (m mapSource) Lookup(name string) (string, bool, error) {
+	v, ok := m[name]
+	return v, ok, nil
+}
+
+func (m mapSource) Names() ([]string, error) {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	return names, nil
+}
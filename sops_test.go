@@ -0,0 +1,66 @@
+package envdecode
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSOPSSource(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake sops command is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "envdecode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The fake sops prints the "encrypted" file, which is its fourth
+	// argument, or fails if it does not exist.
+	fake := filepath.Join(dir, "sops")
+	if err := ioutil.WriteFile(fake, []byte("#!/bin/sh\nexec cat \"$4\"\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	defer func(cmd string) { sopsCommand = cmd }(sopsCommand)
+	sopsCommand = fake
+
+	secrets := filepath.Join(dir, "secrets.env")
+	contents := "# comment\n\nTEST_SOURCE_PASSWORD=hunter2\nTEST_SOURCE_TOKEN=line one\\nline two\nTEST_SOURCE_HOST=\"quoted\"\n"
+	if err := ioutil.WriteFile(secrets, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := SOPSSource(secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tc testConfigSource
+	if err := DecodeWithOptions(&tc, WithSources(src)); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Password != "hunter2" {
+		t.Fatalf(`Expected "hunter2", got %q`, tc.Password)
+	}
+	if tc.Token != "line one\nline two" {
+		t.Fatalf(`Expected "line one\nline two", got %q`, tc.Token)
+	}
+	if tc.Host != `"quoted"` {
+		t.Fatalf(`Expected "\"quoted\"", got %q`, tc.Host)
+	}
+
+	if _, err := SOPSSource(filepath.Join(dir, "missing.env")); err == nil {
+		t.Fatal("Expected an error decrypting a missing file")
+	}
+
+	if err := ioutil.WriteFile(secrets, []byte("not a variable\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SOPSSource(secrets); err == nil {
+		t.Fatal("Expected an error parsing invalid output")
+	}
+}
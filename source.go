@@ -0,0 +1,366 @@
+package envdecode
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A Source supplies the values of variables, in place of or in addition
+// to the environment.
+type Source interface {
+	// Lookup returns the value of the named variable and whether it
+	// is set.  An error fails the decode.
+	Lookup(name string) (value string, ok bool, err error)
+}
+
+// A SourceDescriber is a Source that can say where the value of a
+// variable came from, such as "vault:secret/db#password", for the
+// Source field of ConfigInfo.  Other sources are described as "source".
+type SourceDescriber interface {
+	Source
+	Describe(name string) string
+}
+
+// A SourceLister is a Source that can list the names of the variables
+// it holds, so that they can be discovered by DecodeInstances.  Other
+// sources are not searched for names.
+type SourceLister interface {
+	Source
+	Names() ([]string, error)
+}
+
+// Descriptions of the sources of values that aren't a Source.
+const (
+	sourceEnv     = "env"
+	sourceDefault = "default"
+)
+
+// describeSource describes where src found the value of name.
+func describeSource(src Source, name string) string {
+	if sd, ok := src.(SourceDescriber); ok {
+		return sd.Describe(name)
+	}
+	return "source"
+}
+
+// SourceFunc adapts a lookup function such as os.LookupEnv to a Source.
+type SourceFunc func(name string) (string, bool)
+
+// Lookup calls f.
+func (f SourceFunc) Lookup(name string) (string, bool, error) {
+	v, ok := f(name)
+	return v, ok, nil
+}
+
+// Environment is the Source for the process environment.
+var Environment Source = envSource{}
+
+type envSource struct{}
+
+func (envSource) Lookup(name string) (string, bool, error) {
+	v, ok := os.LookupEnv(name)
+	return v, ok, nil
+}
+
+func (envSource) Describe(name string) string {
+	return sourceEnv
+}
+
+func (envSource) Names() ([]string, error) {
+	return environNames(os.Environ()), nil
+}
+
+// environNames returns the names of the variables in env, in the form
+// of os.Environ.
+func environNames(env []string) []string {
+	names := make([]string, 0, len(env))
+	for _, kv := range env {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			names = append(names, kv[:i])
+		}
+	}
+	return names
+}
+
+// WithSources looks variables up in each of sources in turn, using the
+// first non-empty value.  The environment is only consulted if
+// Environment is among them.
+func WithSources(sources ...Source) Option {
+	return func(o *options) {
+		o.sources = sources
+	}
+}
+
+// WithSnapshot copies the environment when decoding starts and reads
+// every variable from the copy, so that concurrent calls to os.Setenv,
+// as made by parallel tests or plugins, can't leave the configuration
+// half old and half new.  Values from other sources are likewise read
+// once per variable.
+func WithSnapshot() Option {
+	return func(o *options) {
+		o.snapshotEnv = true
+	}
+}
+
+// EnvironSource returns a Source for the variables in env, a slice of
+// "KEY=value" strings as returned by os.Environ or held in the Env of
+// an exec.Cmd.  As for exec.Cmd, the last value of a variable given more
+// than once is used.  Values are described as "env".
+func EnvironSource(env []string) Source {
+	m := make(mapSource, len(env))
+	for _, kv := range env {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			m[kv[:i]] = kv[i+1:]
+		}
+	}
+	return environSource{m}
+}
+
+// environSource is the Source returned by EnvironSource.
+type environSource struct {
+	mapSource
+}
+
+func (e environSource) Describe(name string) string {
+	return sourceEnv
+}
+
+// DecodeFrom decodes target as DecodeWithOptions would, reading
+// variables from env, in the form of os.Environ, rather than the
+// process environment, so that a supervisor can decode the intended
+// environment of a child process without modifying its own.  Sources
+// given in opts are replaced by env.
+func DecodeFrom(target interface{}, env []string, opts ...Option) error {
+	return DecodeWithOptions(target, append(opts, WithSources(EnvironSource(env)))...)
+}
+
+// QuerySource returns a Source for the variables in a query string such
+// as "PORT=8080&HOST=example.com", for orchestrators that pass the
+// whole configuration as a single opaque string.  See ValuesSource.
+func QuerySource(query string) (Source, error) {
+	v, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("envdecode: parsing query: %v", err)
+	}
+	return ValuesSource(v), nil
+}
+
+// ValuesSource returns a Source for the variables in v.  A variable
+// given more than once has its values joined by semicolons, so
+// "HOSTS=a&HOSTS=b" decodes into a slice like HOSTS="a;b".  Values are
+// described as "query".
+func ValuesSource(v url.Values) Source {
+	return valuesSource(v)
+}
+
+// valuesSource is the Source returned by ValuesSource.
+type valuesSource url.Values
+
+func (v valuesSource) Lookup(name string) (string, bool, error) {
+	values, ok := v[name]
+	return strings.Join(values, ";"), ok, nil
+}
+
+func (v valuesSource) Describe(name string) string {
+	return "query"
+}
+
+func (v valuesSource) Names() ([]string, error) {
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// defaultSecretsDir is where Docker Swarm and Kubernetes conventionally
+// mount secrets.
+const defaultSecretsDir = "/run/secrets"
+
+// WithRunSecrets looks up variables that are not set in the
+// environment, or in the sources given by WithSources, as files in
+// /run/secrets, following the Docker Swarm and Kubernetes convention.
+// /run/secrets is consulted last whatever the order of the options.
+// See DirSource.
+func WithRunSecrets() Option {
+	return func(o *options) {
+		o.runSecrets = true
+	}
+}
+
+// dirSource is the Source returned by DirSource.
+type dirSource struct {
+	dir string
+}
+
+// DirSource returns a Source reading each variable from the file in dir
+// with the same name, or failing that its lower-cased name, such as
+// /run/secrets/db_password for DB_PASSWORD.  The contents are used
+// verbatim, so multi-line values such as PEM blocks are preserved,
+// except that a single trailing newline is removed unless
+// WithTrailingNewlines is given.  Missing files are treated as unset
+// variables, while other errors, such as files larger than 1 MiB, fail
+// the decode.  With WithPrivateSecretFiles, files accessible by other
+// users are rejected.  Values are described as "file:" followed by the
+// path of the file.
+func DirSource(dir string) Source {
+	return &dirSource{dir: dir}
+}
+
+func (s *dirSource) Lookup(name string) (string, bool, error) {
+	v, path, err := s.lookup(name, &options{})
+	return v, path != "", err
+}
+
+// lookup reads the file for name, honoring the options for file
+// permissions and trailing newlines, and returns its contents and
+// path.  The path is empty if there is no such file.
+func (s *dirSource) lookup(name string, o *options) (string, string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", "", nil
+	}
+
+	for _, n := range []string{name, strings.ToLower(name)} {
+		path := filepath.Join(s.dir, n)
+		b, err := readFile(path, defaultMaxFileSize, o.privateSecretFiles)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+		if o.keepNewlines {
+			return string(b), path, nil
+		}
+		return trimNewline(string(b)), path, nil
+	}
+	return "", "", nil
+}
+
+// trimNewline removes a single trailing newline, as left by editors and
+// echo, from the contents of a file.
+func trimNewline(s string) string {
+	if strings.HasSuffix(s, "\r\n") {
+		return s[:len(s)-2]
+	}
+	return strings.TrimSuffix(s, "\n")
+}
+
+// WithTrailingNewlines keeps the trailing newline of values read from
+// files by a DirSource, which is otherwise removed.
+func WithTrailingNewlines() Option {
+	return func(o *options) {
+		o.keepNewlines = true
+	}
+}
+
+// WithFileDescriptors reads values of the form "fd:N", such as
+// DB_PASSWORD=fd:3, from the inherited file descriptor N, so that a
+// wrapper can pipe a secret to the process without putting it in the
+// environment or on disk.  Each descriptor is read to the end, up to
+// 1 MiB, and closed the first time it is referred to; later references
+// reuse its contents.  A single trailing newline is removed unless
+// WithTrailingNewlines is given.
+func WithFileDescriptors() Option {
+	return func(o *options) {
+		o.fileDescriptors = true
+	}
+}
+
+// descriptors holds the contents of the file descriptors read for
+// "fd:N" values, which can only be read once.
+var descriptors struct {
+	sync.Mutex
+	contents map[uintptr]string
+}
+
+// readDescriptor returns the contents of the file descriptor referred
+// to by ref, of the form "fd:N".
+func (d *decodeState) readDescriptor(ref string) (string, error) {
+	fd, err := strconv.ParseUint(strings.TrimPrefix(ref, "fd:"), 10, 31)
+	if err != nil {
+		return "", fmt.Errorf("invalid file descriptor %q", ref)
+	}
+
+	descriptors.Lock()
+	defer descriptors.Unlock()
+
+	v, ok := descriptors.contents[uintptr(fd)]
+	if !ok {
+		fp := os.NewFile(uintptr(fd), ref)
+		if fp == nil {
+			return "", fmt.Errorf("invalid file descriptor %q", ref)
+		}
+		b, err := readAll(fp, ref)
+		fp.Close()
+		if err != nil {
+			return "", err
+		}
+		if descriptors.contents == nil {
+			descriptors.contents = map[uintptr]string{}
+		}
+		v = string(b)
+		descriptors.contents[uintptr(fd)] = v
+	}
+
+	if d.keepNewlines {
+		return v, nil
+	}
+	return trimNewline(v), nil
+}
+
+// StdinSource returns a Source supplying the variable name from
+// standard input, as in `echo "$PASSWORD" | app`, so that a single
+// secret can be piped to the process.  Standard input is read to the
+// end, up to 1 MiB, the first time the variable is looked up, and a
+// single trailing newline is removed.  Values are described as
+// "stdin".
+func StdinSource(name string) Source {
+	return &readerSource{name: name, r: os.Stdin}
+}
+
+// readerSource is the Source returned by StdinSource.
+type readerSource struct {
+	name string
+	r    io.Reader
+
+	once  sync.Once
+	value string
+	err   error
+}
+
+func (s *readerSource) Lookup(name string) (string, bool, error) {
+	if name != s.name {
+		return "", false, nil
+	}
+	s.once.Do(func() {
+		var b []byte
+		b, s.err = readAll(s.r, "stdin")
+		s.value = trimNewline(string(b))
+	})
+	return s.value, s.err == nil, s.err
+}
+
+func (s *readerSource) Describe(name string) string {
+	return "stdin"
+}
+
+// readAll reads r, named what, to the end, refusing more than
+// defaultMaxFileSize bytes.
+func readAll(r io.Reader, what string) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, defaultMaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > defaultMaxFileSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", what, defaultMaxFileSize)
+	}
+	return b, nil
+}
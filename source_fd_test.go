@@ -0,0 +1,68 @@
+//go:build !windows && !plan9
+
+package envdecode
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// pipeDescriptor returns a file descriptor from which contents can be
+// read, as a wrapper process would pass it.
+func pipeDescriptor(t *testing.T, contents string) int {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := w.WriteString(contents); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	fd, err := syscall.Dup(int(r.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+func TestWithFileDescriptors(t *testing.T) {
+	fd := pipeDescriptor(t, "hunter2\n")
+	os.Setenv("TEST_FD_PASSWORD", fmt.Sprintf("fd:%d", fd))
+	defer os.Unsetenv("TEST_FD_PASSWORD")
+
+	var tc struct {
+		Password string `env:"TEST_FD_PASSWORD"`
+	}
+	if err := DecodeWithOptions(&tc); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Password != fmt.Sprintf("fd:%d", fd) {
+		t.Fatalf("Expected the reference to be left alone without the option, got %q", tc.Password)
+	}
+
+	// The descriptor is read once and its contents reused.
+	for i := 0; i < 2; i++ {
+		rc, err := Preview(&tc, WithFileDescriptors())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rc[0].Source != fmt.Sprintf("fd:%d", fd) {
+			t.Fatalf("Unexpected source %q", rc[0].Source)
+		}
+		if err := DecodeWithOptions(&tc, WithFileDescriptors()); err != nil {
+			t.Fatal(err)
+		}
+		if tc.Password != "hunter2" {
+			t.Fatalf("Expected %q, got %q", "hunter2", tc.Password)
+		}
+	}
+
+	os.Setenv("TEST_FD_PASSWORD", "fd:three")
+	if err := DecodeWithOptions(&tc, WithFileDescriptors()); err == nil {
+		t.Fatal("Expected an error for an invalid descriptor")
+	}
+}
@@ -0,0 +1,425 @@
+package envdecode
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type testConfigSource struct {
+	Host     string `env:"TEST_SOURCE_HOST"`
+	Password string `env:"TEST_SOURCE_PASSWORD"`
+	Token    string `env:"TEST_SOURCE_TOKEN"`
+	Port     int    `env:"TEST_SOURCE_PORT,default=$TEST_SOURCE_DEFAULT_PORT|80"`
+}
+
+func TestWithSources(t *testing.T) {
+	os.Setenv("TEST_SOURCE_HOST", "env.example.com")
+	os.Setenv("TEST_SOURCE_PASSWORD", "from-env")
+	defer os.Unsetenv("TEST_SOURCE_HOST")
+	defer os.Unsetenv("TEST_SOURCE_PASSWORD")
+
+	values := map[string]string{
+		"TEST_SOURCE_HOST":         "map.example.com",
+		"TEST_SOURCE_TOKEN":        "from-map",
+		"TEST_SOURCE_DEFAULT_PORT": "8080",
+	}
+	m := SourceFunc(func(name string) (string, bool) {
+		v, ok := values[name]
+		return v, ok
+	})
+
+	var tc testConfigSource
+	if err := DecodeWithOptions(&tc, WithSources(Environment, m)); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Host != "env.example.com" || tc.Password != "from-env" || tc.Token != "from-map" || tc.Port != 8080 {
+		t.Fatalf("Unexpected values %+v", tc)
+	}
+
+	tc = testConfigSource{}
+	if err := DecodeWithOptions(&tc, WithSources(m)); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Host != "map.example.com" || tc.Password != "" {
+		t.Fatalf("Expected the environment to be ignored, got %+v", tc)
+	}
+}
+
+func TestDirSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envdecode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "test_source_password"), []byte("hunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "TEST_SOURCE_TOKEN"), []byte("line one\nline two\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("TEST_SOURCE_HOST", "env.example.com")
+	defer os.Unsetenv("TEST_SOURCE_HOST")
+
+	var tc testConfigSource
+	if err := DecodeWithOptions(&tc, WithSources(Environment, DirSource(dir))); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Host != "env.example.com" {
+		t.Fatalf(`Expected "env.example.com", got %q`, tc.Host)
+	}
+	if tc.Password != "hunter2" {
+		t.Fatalf(`Expected "hunter2", got %q`, tc.Password)
+	}
+	if tc.Token != "line one\nline two" {
+		t.Fatalf(`Expected "line one\nline two", got %q`, tc.Token)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if err := os.Chmod(filepath.Join(dir, "test_source_password"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecodeWithOptions(&tc, WithSources(DirSource(dir)), WithPrivateSecretFiles()); err == nil {
+		t.Fatal("Expected an error reading a world-readable secret file")
+	}
+}
+
+func TestWithRunSecrets(t *testing.T) {
+	d := newDecodeState([]Option{WithRunSecrets()})
+	if len(d.sources) != 2 || d.sources[0] != Environment {
+		t.Fatalf("Expected the environment followed by %s, got %v", defaultSecretsDir, d.sources)
+	}
+	if ds, ok := d.sources[1].(*dirSource); !ok || ds.dir != defaultSecretsDir {
+		t.Fatalf("Expected a DirSource for %s, got %v", defaultSecretsDir, d.sources[1])
+	}
+
+	// The order of the options doesn't matter.
+	d = newDecodeState([]Option{WithRunSecrets(), WithSources(EnvironSource(nil))})
+	if _, ok := d.sources[0].(environSource); len(d.sources) != 2 || !ok {
+		t.Fatalf("Expected the given source followed by %s, got %v", defaultSecretsDir, d.sources)
+	}
+	if ds, ok := d.sources[1].(*dirSource); !ok || ds.dir != defaultSecretsDir {
+		t.Fatalf("Expected a DirSource for %s, got %v", defaultSecretsDir, d.sources[1])
+	}
+}
+
+type testConfigPEM struct {
+	PEM string            `env:"TEST_PEM"`
+	Key *ecdsa.PrivateKey `env:"TEST_PEM_KEY,strict"`
+}
+
+func TestPEMRoundTrip(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+
+	dir, err := ioutil.TempDir("", "envdecode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"TEST_PEM", "TEST_PEM_KEY"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), pemBytes, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(tc testConfigPEM, expected string) {
+		t.Helper()
+		if tc.PEM != expected {
+			t.Fatalf("Expected %q, got %q", expected, tc.PEM)
+		}
+		if tc.Key == nil || !tc.Key.Equal(key) {
+			t.Fatal("Expected the decoded key to match the original")
+		}
+	}
+
+	// Files in a directory source lose only their trailing newline.
+	var tc testConfigPEM
+	if err := DecodeWithOptions(&tc, WithSources(DirSource(dir))); err != nil {
+		t.Fatal(err)
+	}
+	check(tc, strings.TrimSuffix(string(pemBytes), "\n"))
+
+	tc = testConfigPEM{}
+	if err := DecodeWithOptions(&tc, WithSources(DirSource(dir)), WithTrailingNewlines()); err != nil {
+		t.Fatal(err)
+	}
+	check(tc, string(pemBytes))
+
+	// Files loaded by a field are used verbatim.
+	var lf struct {
+		PEM string            `env:"TEST_PEM,loadfile"`
+		Key *ecdsa.PrivateKey `env:"TEST_PEM_KEY,loadfile,strict"`
+	}
+	os.Setenv("TEST_PEM", filepath.Join(dir, "TEST_PEM"))
+	os.Setenv("TEST_PEM_KEY", filepath.Join(dir, "TEST_PEM_KEY"))
+	defer os.Unsetenv("TEST_PEM")
+	defer os.Unsetenv("TEST_PEM_KEY")
+	if err := Decode(&lf); err != nil {
+		t.Fatal(err)
+	}
+	check(testConfigPEM(lf), string(pemBytes))
+
+	// Newlines escaped by sops are restored.
+	values, err := parseSOPSDotenv([]byte("TEST_PEM=" + strings.Replace(string(pemBytes), "\n", `\n`, -1) + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values["TEST_PEM_KEY"] = values["TEST_PEM"]
+	tc = testConfigPEM{}
+	if err := DecodeWithOptions(&tc, WithSources(mapSource(values))); err != nil {
+		t.Fatal(err)
+	}
+	check(tc, string(pemBytes))
+}
+
+type vaultSource map[string]string
+
+func (v vaultSource) Lookup(name string) (string, bool, error) {
+	s, ok := v[name]
+	return s, ok, nil
+}
+
+func (v vaultSource) Describe(name string) string {
+	return "vault:secret/" + strings.ToLower(name)
+}
+
+func TestPreviewSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envdecode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	password := filepath.Join(dir, "test_source_password")
+	if err := ioutil.WriteFile(password, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("TEST_SOURCE_HOST", "env.example.com")
+	defer os.Unsetenv("TEST_SOURCE_HOST")
+
+	var tc struct {
+		Host     string `env:"TEST_SOURCE_HOST"`
+		Password string `env:"TEST_SOURCE_PASSWORD,secret"`
+		Token    string `env:"TEST_SOURCE_TOKEN,secret"`
+		Port     int    `env:"TEST_SOURCE_PORT,default=8080"`
+		Name     string `env:"TEST_SOURCE_NAME"`
+	}
+	vault := vaultSource{"TEST_SOURCE_TOKEN": "s3cr3t", "TEST_SOURCE_NAME": "name"}
+	rc, err := Preview(&tc, WithSources(Environment, DirSource(dir), vault, SourceFunc(func(string) (string, bool) { return "", false })))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"Host":     "env",
+		"Name":     "vault:secret/test_source_name",
+		"Password": "file:" + password,
+		"Port":     "default",
+		"Token":    "vault:secret/test_source_token",
+	}
+	for _, ci := range rc {
+		if ci.Source != expected[ci.Field] {
+			t.Fatalf("Expected %s to come from %q, got %q", ci.Field, expected[ci.Field], ci.Source)
+		}
+	}
+
+	rc, err = Preview(&tc, WithSources(SourceFunc(os.LookupEnv)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rc[0].Field != "Host" || rc[0].Source != "source" {
+		t.Fatalf("Expected Host to come from %q, got %+v", "source", rc[0])
+	}
+}
+
+func TestWithSnapshot(t *testing.T) {
+	type config struct {
+		Trigger settingDecoder `env:"TEST_ALL_TRIGGER"`
+		Shared  string         `env:"TEST_ALL_SHARED"`
+	}
+	os.Setenv("TEST_ALL_TRIGGER", "trigger")
+	defer os.Unsetenv("TEST_ALL_TRIGGER")
+	defer os.Unsetenv("TEST_ALL_SHARED")
+
+	os.Setenv("TEST_ALL_SHARED", "original")
+	var tc config
+	if err := DecodeWithOptions(&tc, WithSnapshot()); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Shared != "original" {
+		t.Fatalf("Expected the snapshot value %q, got %q", "original", tc.Shared)
+	}
+
+	os.Setenv("TEST_ALL_SHARED", "original")
+	tc = config{}
+	if err := DecodeWithOptions(&tc); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Shared != "changed" {
+		t.Fatalf("Expected the changed value without a snapshot, got %q", tc.Shared)
+	}
+
+	calls := 0
+	src := SourceFunc(func(name string) (string, bool) {
+		calls++
+		return os.LookupEnv(name)
+	})
+	var shared struct {
+		A string `env:"TEST_ALL_SHARED,shared"`
+		B string `env:"TEST_ALL_SHARED,shared"`
+	}
+	if err := DecodeWithOptions(&shared, WithSources(src), WithSnapshot()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("Expected one lookup, got %d", calls)
+	}
+}
+
+func TestStdinSource(t *testing.T) {
+	src := &readerSource{name: "TEST_STDIN_PASSWORD", r: strings.NewReader("hunter2\n")}
+
+	var tc struct {
+		Password string `env:"TEST_STDIN_PASSWORD"`
+		Other    string `env:"TEST_STDIN_OTHER,default=other"`
+	}
+	rc, err := Preview(&tc, WithSources(Environment, src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rc[1].Value != "hunter2" || rc[1].Source != "stdin" {
+		t.Fatalf("Expected the password from stdin, got %+v", rc[1])
+	}
+	if rc[0].Value != "other" {
+		t.Fatalf("Expected the other variable to be unaffected, got %+v", rc[0])
+	}
+
+	// Standard input can only be read once.
+	if err := DecodeWithOptions(&tc, WithSources(src)); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Password != "hunter2" {
+		t.Fatalf("Expected %q, got %q", "hunter2", tc.Password)
+	}
+
+	big := &readerSource{name: "TEST_STDIN_PASSWORD", r: strings.NewReader(strings.Repeat("x", defaultMaxFileSize+1))}
+	if err := DecodeWithOptions(&tc, WithSources(big)); err == nil {
+		t.Fatal("Expected an error for oversized input")
+	}
+}
+
+func TestQuerySource(t *testing.T) {
+	src, err := QuerySource("TEST_QUERY_HOST=example.com&TEST_QUERY_PORT=8080&TEST_QUERY_TAGS=a&TEST_QUERY_TAGS=b%3Bc&TEST_QUERY_EMPTY=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tc struct {
+		Host  string   `env:"TEST_QUERY_HOST"`
+		Port  int      `env:"TEST_QUERY_PORT"`
+		Tags  []string `env:"TEST_QUERY_TAGS"`
+		Empty string   `env:"TEST_QUERY_EMPTY,default=default"`
+	}
+	rc, err := Preview(&tc, WithSources(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DecodeWithOptions(&tc, WithSources(src)); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Host != "example.com" || tc.Port != 8080 || tc.Empty != "default" {
+		t.Fatalf("Unexpected config %+v", tc)
+	}
+	if !reflect.DeepEqual(tc.Tags, []string{"a", "b", "c"}) {
+		t.Fatalf("Unexpected tags %v", tc.Tags)
+	}
+	if rc[1].Field != "Host" || rc[1].Source != "query" {
+		t.Fatalf("Expected Host to come from the query, got %+v", rc[1])
+	}
+
+	if _, err := QuerySource("TEST_QUERY_HOST=%zz"); err == nil {
+		t.Fatal("Expected an error for an invalid query")
+	}
+}
+
+func TestDecodeWithLookup(t *testing.T) {
+	env := map[string]string{"TEST_SOURCE_HOST": "fake.example.com"}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	os.Setenv("TEST_SOURCE_PASSWORD", "hunter2")
+	defer os.Unsetenv("TEST_SOURCE_PASSWORD")
+
+	var tc testConfigSource
+	if err := DecodeWithLookup(&tc, lookup); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Host != "fake.example.com" || tc.Password != "" || tc.Port != 80 {
+		t.Fatalf("Expected only the fake environment to be read, got %+v", tc)
+	}
+
+	env["TEST_SOURCE_DEFAULT_PORT"] = "8080"
+	if err := DecodeWithLookup(&tc, lookup); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Port != 8080 {
+		t.Fatalf("Expected defaults to be resolved with the lookup, got %d", tc.Port)
+	}
+}
+
+func TestDecodeFrom(t *testing.T) {
+	os.Setenv("TEST_SOURCE_HOST", "parent.example.com")
+	defer os.Unsetenv("TEST_SOURCE_HOST")
+
+	env := []string{
+		"TEST_SOURCE_HOST=child.example.com",
+		"TEST_SOURCE_TOKEN=a=b",
+		"TEST_SOURCE_PORT=8080",
+		"TEST_SOURCE_PORT=9090",
+		"MALFORMED",
+	}
+	var tc testConfigSource
+	if err := DecodeFrom(&tc, env); err != nil {
+		t.Fatal(err)
+	}
+	expected := testConfigSource{Host: "child.example.com", Token: "a=b", Port: 9090}
+	if tc != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, tc)
+	}
+	if os.Getenv("TEST_SOURCE_HOST") != "parent.example.com" {
+		t.Fatal("Expected the process environment to be untouched")
+	}
+
+	var empty testConfigSource
+	if err := DecodeFrom(&empty, nil); err != nil {
+		t.Fatal(err)
+	}
+	if empty.Host != "" || empty.Port != 80 {
+		t.Fatalf("Expected only the default, got %+v", empty)
+	}
+}
@@ -0,0 +1,75 @@
+package envdecode
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs returns the functions available to the templates of
+// fields tagged ",template", reading variables with lookup.
+func templateFuncs(lookup func(name string) (string, error)) template.FuncMap {
+	return template.FuncMap{"env": lookup}
+}
+
+// parseTemplate parses the template of a field tagged ",template".
+func parseTemplate(text string, lookup func(name string) (string, error)) (*template.Template, error) {
+	return template.New("").Funcs(templateFuncs(lookup)).Parse(text)
+}
+
+// WithTemplateData expands variable names in tags written as
+// text/template templates, such as
+//
+//	URL string `env:"{{.Service}}_DB_URL"`
+//
+// with data, so that shared libraries can decode service-specific
+// variables.  Referring to a key or field missing from data is an
+// error.
+func WithTemplateData(data interface{}) Option {
+	return func(o *options) {
+		o.templateData = data
+	}
+}
+
+// expandName expands the variable name from a tag if it is a template.
+func (d *decodeState) expandName(name string) (string, error) {
+	if !strings.Contains(name, "{{") {
+		return name, nil
+	}
+	if d.templateData == nil {
+		return name, fmt.Errorf("variable name %q is a template, but WithTemplateData wasn't given", name)
+	}
+	tmpl, err := template.New("").Option("missingkey=error").Parse(name)
+	if err != nil {
+		return name, fmt.Errorf("parsing variable name %q: %v", name, err)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, d.templateData); err != nil {
+		return name, fmt.Errorf("expanding variable name %q: %v", name, err)
+	}
+	return buf.String(), nil
+}
+
+// renderTemplate renders the template of a field tagged ",template",
+// returning "" if none of the variables it reads are set.  The value is
+// described as "template".
+func (d *decodeState) renderTemplate(opts tagOptions) (string, string, error) {
+	set := false
+	tmpl, err := parseTemplate(opts.template, func(name string) (string, error) {
+		v, _, err := d.lookup(name)
+		set = set || v != ""
+		return v, err
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("envdecode: parsing template: %v", err)
+	}
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return "", "", fmt.Errorf("envdecode: rendering template: %v", err)
+	}
+	if !set {
+		return "", "", nil
+	}
+	return buf.String(), "template", nil
+}
@@ -0,0 +1,29 @@
+package docs
+
+import "net/url"
+
+// Config is parsed by TestParseDocs.
+type Config struct {
+	// Host is the name the server
+	// listens on.
+	Host string `env:"HOST"`
+
+	Port int `env:"PORT"` // Port to listen on
+
+	// Database settings
+	Database struct {
+		// URL of the database
+		URL *url.URL `env:"DATABASE_URL"`
+	}
+
+	Cache *Cache
+
+	Undocumented string `env:"UNDOCUMENTED"`
+
+	Parent *Config
+}
+
+type Cache struct {
+	// TTL of entries
+	TTL int `env:"CACHE_TTL"`
+}
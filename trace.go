@@ -0,0 +1,58 @@
+package envdecode
+
+import (
+	"reflect"
+	"time"
+)
+
+// DecodeStats summarizes a single decode, for tracing.
+type DecodeStats struct {
+	// Target is the type of the target, such as "*main.Config".
+	Target string
+
+	// Fields is the number of tagged fields decoded.
+	Fields int
+
+	// Set is the number of fields whose variables were set.
+	Set int
+
+	// Defaults is the number of fields given their default.
+	Defaults int
+
+	// Lookups is the number of times a source, or the environment, was
+	// consulted for a variable.
+	Lookups int
+
+	// Duration is how long the decode took.
+	Duration time.Duration
+
+	// Err is the error the decode returned, if any.
+	Err error
+}
+
+// WithTrace calls start when the decode starts, and the function it
+// returns with the statistics of the decode when it ends, so that slow
+// startups, such as those consulting remote sources, show up in
+// traces.  envdecode doesn't depend on any tracing library; with
+// OpenTelemetry, start would start a span and the function it returns
+// set its attributes and end it.
+func WithTrace(start func() func(DecodeStats)) Option {
+	return func(o *options) {
+		o.trace = start
+	}
+}
+
+// startTrace starts tracing the decode of target, returning the
+// function that ends it.
+func (d *decodeState) startTrace(target interface{}) func(error) {
+	end := d.trace()
+	d.stats = &DecodeStats{Target: reflect.TypeOf(target).String()}
+	begin := time.Now()
+	return func(err error) {
+		stats := *d.stats
+		d.stats = nil
+		stats.Duration = time.Since(begin)
+		stats.Err = err
+		end(stats)
+	}
+}
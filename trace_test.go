@@ -0,0 +1,48 @@
+package envdecode
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+type testConfigTrace struct {
+	Host  string `env:"TEST_TRACE_HOST"`
+	Port  int    `env:"TEST_TRACE_PORT,default=8080"`
+	Name  string `env:"TEST_TRACE_NAME"`
+	Debug bool   `env:"TEST_TRACE_DEBUG,strict"`
+}
+
+func TestWithTrace(t *testing.T) {
+	os.Setenv("TEST_TRACE_HOST", "localhost")
+	defer os.Unsetenv("TEST_TRACE_HOST")
+
+	var spans []DecodeStats
+	trace := WithTrace(func() func(DecodeStats) {
+		return func(s DecodeStats) {
+			spans = append(spans, s)
+		}
+	})
+
+	var tc testConfigTrace
+	if err := DecodeWithOptions(&tc, trace, WithSources(Environment, SourceFunc(func(string) (string, bool) { return "", false }))); err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 1 {
+		t.Fatalf("Expected one span, got %d", len(spans))
+	}
+	s := spans[0]
+	if s.Target != "*envdecode.testConfigTrace" || s.Fields != 4 || s.Set != 1 || s.Defaults != 1 || s.Lookups != 7 || s.Err != nil {
+		t.Fatalf("Unexpected stats %+v", s)
+	}
+
+	os.Setenv("TEST_TRACE_DEBUG", "maybe")
+	defer os.Unsetenv("TEST_TRACE_DEBUG")
+	err := DecodeWithOptions(&tc, trace)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if len(spans) != 2 || !errors.Is(spans[1].Err, err) {
+		t.Fatalf("Expected the span to record the error, got %+v", spans)
+	}
+}
@@ -0,0 +1,49 @@
+package envdecode
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	transformsMu sync.RWMutex
+	transforms   = map[string]func(string) (string, error){}
+)
+
+// RegisterTransform registers fn under name for use by fields tagged
+// ",transform=name", which pass their value through fn before it is
+// decoded, so that normalization such as hashing or case folding can
+// be shared across an organization rather than repeated in Decoders.
+// Several transforms may be applied in turn, as in
+// ",transform=trim;lower".  Registering a second transform under the
+// same name replaces the first.
+func RegisterTransform(name string, fn func(string) (string, error)) {
+	if name == "" || fn == nil {
+		panic("envdecode: RegisterTransform called with empty name or nil function")
+	}
+
+	transformsMu.Lock()
+	transforms[name] = fn
+	transformsMu.Unlock()
+}
+
+func transform(name string) func(string) (string, error) {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	return transforms[name]
+}
+
+// applyTransforms passes s through the transforms named by opts.
+func applyTransforms(s string, opts tagOptions) (string, error) {
+	for _, name := range opts.transforms {
+		fn := transform(name)
+		if fn == nil {
+			return "", fmt.Errorf("unknown transform %q", name)
+		}
+		var err error
+		if s, err = fn(s); err != nil {
+			return "", fmt.Errorf("transform %s: %v", name, err)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,70 @@
+package envdecode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func init() {
+	RegisterTransform("test_sha256hex", func(s string) (string, error) {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:]), nil
+	})
+	RegisterTransform("test_lower", func(s string) (string, error) {
+		return strings.ToLower(s), nil
+	})
+	RegisterTransform("test_nonempty", func(s string) (string, error) {
+		if strings.TrimSpace(s) == "" {
+			return "", errors.New("value is blank")
+		}
+		return s, nil
+	})
+}
+
+type testConfigTransform struct {
+	Token  string   `env:"TEST_TRANSFORM_TOKEN,transform=test_sha256hex"`
+	Region string   `env:"TEST_TRANSFORM_REGION,transform=test_nonempty;test_lower"`
+	Zones  []string `env:"TEST_TRANSFORM_ZONES,transform=test_lower,default=EU-1;EU-2"`
+}
+
+func TestRegisterTransform(t *testing.T) {
+	os.Setenv("TEST_TRANSFORM_TOKEN", "hunter2")
+	os.Setenv("TEST_TRANSFORM_REGION", "EU-West")
+	defer os.Unsetenv("TEST_TRANSFORM_TOKEN")
+	defer os.Unsetenv("TEST_TRANSFORM_REGION")
+
+	var tc testConfigTransform
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("hunter2"))
+	if tc.Token != hex.EncodeToString(sum[:]) || tc.Region != "eu-west" {
+		t.Fatalf("Unexpected config %+v", tc)
+	}
+	if len(tc.Zones) != 2 || tc.Zones[0] != "eu-1" {
+		t.Fatalf("Unexpected zones %q", tc.Zones)
+	}
+	if err := ValidateStruct(&tc); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("TEST_TRANSFORM_REGION", " ")
+	err := Decode(&tc)
+	if err == nil || !strings.Contains(err.Error(), "transform test_nonempty: value is blank") {
+		t.Fatalf("Expected a transform error, got %v", err)
+	}
+
+	type unknownTransform struct {
+		Region string `env:"TEST_TRANSFORM_REGION,transform=test_missing"`
+	}
+	if err := Decode(&unknownTransform{}); err == nil || !strings.Contains(err.Error(), `unknown transform "test_missing"`) {
+		t.Fatalf("Expected an unknown transform error, got %v", err)
+	}
+	if err := ValidateStruct(&unknownTransform{}); err == nil || !strings.Contains(err.Error(), `unknown transform "test_missing"`) {
+		t.Fatalf("Expected an unknown transform error, got %v", err)
+	}
+}
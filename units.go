@@ -0,0 +1,208 @@
+package envdecode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rangeError is returned for a well-formed value outside the range its
+// unit allows.  Unlike a value that can't be converted, it is reported
+// even when the field isn't strict.
+type rangeError struct {
+	error
+}
+
+// isRangeError reports whether err is, or wraps, a rangeError.
+func isRangeError(err error) bool {
+	var re rangeError
+	return errors.As(err, &re)
+}
+
+// units are the values understood by the "unit" option.
+var units = map[string]bool{
+	"rate":       true,
+	"bytes":      true,
+	"percent":    true,
+	"percent100": true,
+}
+
+// unitFor returns the unit values of type t are decoded in: the unit
+// given by the "unit" option, or the unit implied by t.
+func unitFor(t reflect.Type, unit string) string {
+	if unit != "" {
+		return unit
+	}
+	if t.PkgPath() == "golang.org/x/time/rate" && t.Name() == "Limit" {
+		return "rate"
+	}
+	return ""
+}
+
+// decodeUnit parses env, a quantity in unit, into the numeric value f.
+func decodeUnit(f *reflect.Value, env, unit string) error {
+	switch unit {
+	case "rate":
+		if k := f.Kind(); k != reflect.Float32 && k != reflect.Float64 {
+			return fmt.Errorf("unit %q requires a floating point field, not %s", unit, f.Type())
+		}
+		v, err := parseRate(env)
+		if err != nil {
+			return err
+		}
+		if f.OverflowFloat(v) {
+			v = math.Inf(1)
+		}
+		f.SetFloat(v)
+		return nil
+
+	case "percent", "percent100":
+		if k := f.Kind(); k != reflect.Float32 && k != reflect.Float64 {
+			return fmt.Errorf("unit %q requires a floating point field, not %s", unit, f.Type())
+		}
+		v, err := parsePercent(env)
+		if err != nil {
+			return err
+		}
+		if unit == "percent" {
+			v /= 100
+		}
+		f.SetFloat(v)
+		return nil
+
+	case "bytes":
+		v, err := parseBytes(env)
+		if err != nil {
+			return err
+		}
+		switch f.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v > math.MaxInt64 || f.OverflowInt(int64(v)) {
+				return fmt.Errorf("%s overflows %s", env, f.Type())
+			}
+			f.SetInt(int64(v))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if f.OverflowUint(v) {
+				return fmt.Errorf("%s overflows %s", env, f.Type())
+			}
+			f.SetUint(v)
+		default:
+			return fmt.Errorf("unit %q requires an integer field, not %s", unit, f.Type())
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown unit %q", unit)
+}
+
+// parseRate parses a rate into events per second.  A rate is a number,
+// taken to be per second, or a number per interval, such as "100/s",
+// "6000/m", "5/min", "1/h" or "10/500ms".  "inf" is an unlimited rate,
+// the same as rate.Inf.
+func parseRate(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "inf") {
+		return math.MaxFloat64, nil
+	}
+
+	num, per := s, ""
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		num, per = strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	if per == "" {
+		return n, nil
+	}
+
+	if per == "min" {
+		per = "m"
+	}
+	if per != "" && (per[0] < '0' || per[0] > '9') {
+		per = "1" + per
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid rate %q: bad interval", s)
+	}
+	return n / d.Seconds(), nil
+}
+
+// byteUnits are the multipliers of the byte size suffixes, decimal and
+// binary, keyed by their lower-cased names.
+var byteUnits = map[string]uint64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kb":  1e3,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mb":  1e6,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gb":  1e9,
+	"gib": 1 << 30,
+	"t":   1 << 40,
+	"tb":  1e12,
+	"tib": 1 << 40,
+	"p":   1 << 50,
+	"pb":  1e15,
+	"pib": 1 << 50,
+	"e":   1 << 60,
+	"eb":  1e18,
+	"eib": 1 << 60,
+}
+
+// parseBytes parses a byte size such as "512MiB", "10GB", "1.5k" or
+// "4096".  Suffixes are case-insensitive; KB, MB and so on are powers
+// of 1000, while KiB, MiB and the single letters K, M and so on are
+// powers of 1024.
+func parseBytes(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	num, suffix := s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+
+	mult, ok := byteUnits[suffix]
+	if !ok || num == "" {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	if n, err := strconv.ParseUint(num, 10, 64); err == nil {
+		if n > math.MaxUint64/mult {
+			return 0, fmt.Errorf("byte size %q is too large", s)
+		}
+		return n * mult, nil
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	v := n * float64(mult)
+	if v >= math.MaxUint64 {
+		return 0, fmt.Errorf("byte size %q is too large", s)
+	}
+	return uint64(v), nil
+}
+
+// parsePercent parses a percentage between 0 and 100, such as "85%" or
+// "12.5", returning the number of percent.
+func parsePercent(s string) (float64, error) {
+	num := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "%"))
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+	if v < 0 || v > 100 || math.IsNaN(v) {
+		return 0, rangeError{fmt.Errorf("percentage %q is not between 0 and 100", s)}
+	}
+	return v, nil
+}
@@ -0,0 +1,153 @@
+package envdecode
+
+import (
+	"math"
+	"os"
+	"testing"
+)
+
+func TestDecodeRate(t *testing.T) {
+	var tc struct {
+		Rate float64 `env:"TEST_RATE,unit=rate,strict"`
+	}
+
+	tests := []struct {
+		value    string
+		expected float64
+	}{
+		{"100", 100},
+		{"100/s", 100},
+		{"6000/m", 100},
+		{"6000/min", 100},
+		{"3600/h", 1},
+		{"10/500ms", 20},
+		{"1.5/2s", 0.75},
+		{"inf", math.MaxFloat64},
+	}
+
+	defer os.Unsetenv("TEST_RATE")
+	for _, test := range tests {
+		os.Setenv("TEST_RATE", test.value)
+		if err := Decode(&tc); err != nil {
+			t.Fatalf("Decoding %q: %v", test.value, err)
+		}
+		if tc.Rate != test.expected {
+			t.Fatalf("Expected %q to decode as %v, got %v", test.value, test.expected, tc.Rate)
+		}
+	}
+
+	for _, value := range []string{"fast", "-1/s", "100/x", "100/0s"} {
+		os.Setenv("TEST_RATE", value)
+		if err := Decode(&tc); err == nil {
+			t.Fatalf("Expected an error decoding %q", value)
+		}
+	}
+
+	var ti struct {
+		Rate int `env:"TEST_RATE,unit=rate,strict"`
+	}
+	os.Setenv("TEST_RATE", "100/s")
+	if err := Decode(&ti); err == nil {
+		t.Fatal("Expected an error decoding a rate into an int")
+	}
+}
+
+func TestDecodeBytes(t *testing.T) {
+	var tc struct {
+		Size  int64  `env:"TEST_BYTES,unit=bytes,strict"`
+		USize uint64 `env:"TEST_BYTES,unit=bytes,strict,shared"`
+	}
+
+	tests := []struct {
+		value    string
+		expected int64
+	}{
+		{"4096", 4096},
+		{"4096B", 4096},
+		{"512MiB", 512 << 20},
+		{"10GB", 10e9},
+		{"10 gb", 10e9},
+		{"64k", 64 << 10},
+		{"1.5KiB", 1536},
+		{"2TB", 2e12},
+	}
+
+	defer os.Unsetenv("TEST_BYTES")
+	for _, test := range tests {
+		os.Setenv("TEST_BYTES", test.value)
+		if err := Decode(&tc); err != nil {
+			t.Fatalf("Decoding %q: %v", test.value, err)
+		}
+		if tc.Size != test.expected || tc.USize != uint64(test.expected) {
+			t.Fatalf("Expected %q to decode as %d, got %d and %d", test.value, test.expected, tc.Size, tc.USize)
+		}
+	}
+
+	for _, value := range []string{"MiB", "10XB", "-1", "16EiB", "1.2.3MB"} {
+		os.Setenv("TEST_BYTES", value)
+		if err := Decode(&tc); err == nil {
+			t.Fatalf("Expected an error decoding %q", value)
+		}
+	}
+
+	var small struct {
+		Size int8 `env:"TEST_BYTES,unit=bytes,strict"`
+	}
+	os.Setenv("TEST_BYTES", "1KiB")
+	if err := Decode(&small); err == nil {
+		t.Fatal("Expected an error decoding a size overflowing int8")
+	}
+}
+
+func TestDecodePercent(t *testing.T) {
+	var tc struct {
+		Fraction float64 `env:"TEST_PERCENT,unit=percent,strict"`
+		Points   float32 `env:"TEST_PERCENT,unit=percent100,strict,shared"`
+	}
+
+	tests := []struct {
+		value    string
+		fraction float64
+		points   float32
+	}{
+		{"85%", 0.85, 85},
+		{"85", 0.85, 85},
+		{"12.5 %", 0.125, 12.5},
+		{"0%", 0, 0},
+		{"100%", 1, 100},
+	}
+
+	defer os.Unsetenv("TEST_PERCENT")
+	for _, test := range tests {
+		os.Setenv("TEST_PERCENT", test.value)
+		if err := Decode(&tc); err != nil {
+			t.Fatalf("Decoding %q: %v", test.value, err)
+		}
+		if tc.Fraction != test.fraction || tc.Points != test.points {
+			t.Fatalf("Expected %q to decode as %v and %v, got %v and %v", test.value, test.fraction, test.points, tc.Fraction, tc.Points)
+		}
+	}
+
+	for _, value := range []string{"101%", "-1%", "half", "NaN"} {
+		os.Setenv("TEST_PERCENT", value)
+		if err := Decode(&tc); err == nil {
+			t.Fatalf("Expected an error decoding %q", value)
+		}
+	}
+
+	// Out of range percentages are rejected even when not strict.
+	var lax struct {
+		Fraction  float64   `env:"TEST_PERCENT,unit=percent"`
+		Fractions []float64 `env:"TEST_PERCENTS,unit=percent"`
+	}
+	os.Setenv("TEST_PERCENT", "150%")
+	if err := Decode(&lax); err == nil {
+		t.Fatal("Expected an error decoding 150% into a field that isn't strict")
+	}
+	os.Setenv("TEST_PERCENT", "50%")
+	os.Setenv("TEST_PERCENTS", "10%;150%")
+	defer os.Unsetenv("TEST_PERCENTS")
+	if err := Decode(&lax); err == nil {
+		t.Fatal("Expected an error decoding 150% into a slice that isn't strict")
+	}
+}
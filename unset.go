@@ -0,0 +1,89 @@
+package envdecode
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// An UnsetPolicy says what happens to a field when its variable is
+// unset while decoding into a struct that already holds a value, such
+// as when configuration is reloaded after a variable was removed.
+type UnsetPolicy int
+
+const (
+	// UnsetDefault sets the field to its default, if it has one, and
+	// otherwise leaves it alone.  It is the policy unless another is
+	// given.
+	UnsetDefault UnsetPolicy = iota
+
+	// UnsetZero sets the field to its default, if it has one, and
+	// otherwise to the zero value of its type.
+	UnsetZero
+
+	// UnsetRetain leaves the field alone, even if it has a default.
+	UnsetRetain
+)
+
+// unsetPolicies are the values understood by the "unset" option.
+var unsetPolicies = map[string]UnsetPolicy{
+	"default": UnsetDefault,
+	"zero":    UnsetZero,
+	"retain":  UnsetRetain,
+}
+
+func (p UnsetPolicy) String() string {
+	for name, policy := range unsetPolicies {
+		if policy == p {
+			return name
+		}
+	}
+	return fmt.Sprintf("UnsetPolicy(%d)", int(p))
+}
+
+// WithUnset sets the policy for fields whose variables are unset,
+// except those with their own ",unset=default", ",unset=zero" or
+// ",unset=retain" option.  A field holding a value that is reset to
+// the zero value or retained raises a warning, see WithWarnings.
+func WithUnset(policy UnsetPolicy) Option {
+	return func(o *options) {
+		o.unset = policy
+	}
+}
+
+// unsetPolicy returns the policy for a field with opts.
+func (d *decodeState) unsetPolicy(opts tagOptions) UnsetPolicy {
+	if opts.hasUnset {
+		return opts.unset
+	}
+	return d.unset
+}
+
+// isZeroValue reports whether f holds the zero value of its type, or
+// for an atomic value whether the value it holds is zero.
+func isZeroValue(f reflect.Value) bool {
+	if isAtomicType(f.Type()) {
+		v := atomicLoad(f)
+		return !v.IsValid() || v.IsZero()
+	}
+	return f.IsZero()
+}
+
+// setZero sets f to the zero value of its type.  Atomic values store
+// the zero value of the type they hold.
+func setZero(f reflect.Value) {
+	if !isAtomicType(f.Type()) {
+		f.Set(reflect.Zero(f.Type()))
+		return
+	}
+
+	store := f.Addr().MethodByName("Store")
+	t := store.Type().In(0)
+	if t.Kind() == reflect.Interface {
+		v := atomicLoad(f)
+		if v.IsNil() {
+			return
+		}
+		t = v.Elem().Type()
+	}
+	store.Call([]reflect.Value{reflect.Zero(t)})
+}
@@ -0,0 +1,95 @@
+package envdecode
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+type testConfigUnset struct {
+	Host    string `env:"TEST_UNSET_HOST"`
+	Port    int    `env:"TEST_UNSET_PORT,default=8080"`
+	Level   string `env:"TEST_UNSET_LEVEL,default=info,unset=retain"`
+	Workers int    `env:"TEST_UNSET_WORKERS,unset=zero"`
+}
+
+func TestWithUnset(t *testing.T) {
+	vars := map[string]string{
+		"TEST_UNSET_HOST":    "example.com",
+		"TEST_UNSET_PORT":    "9090",
+		"TEST_UNSET_LEVEL":   "debug",
+		"TEST_UNSET_WORKERS": "4",
+	}
+	set := func() {
+		for k, v := range vars {
+			os.Setenv(k, v)
+		}
+	}
+	unset := func() {
+		for k := range vars {
+			os.Unsetenv(k)
+		}
+	}
+	defer unset()
+
+	var warnings []string
+	warn := WithWarnings(func(w Warning) { warnings = append(warnings, w.Message) })
+
+	// By default, fields revert to their defaults or are left alone,
+	// unless they have their own policy.
+	set()
+	var tc testConfigUnset
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+	unset()
+	os.Setenv("TEST_UNSET_HOST", "example.com")
+	if err := DecodeWithOptions(&tc, warn); err != nil {
+		t.Fatal(err)
+	}
+	expected := testConfigUnset{Host: "example.com", Port: 8080, Level: "debug", Workers: 0}
+	if tc != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, tc)
+	}
+	if strings.Join(warnings, "\n") != "TEST_UNSET_LEVEL is unset; keeping the previous value\nTEST_UNSET_WORKERS is unset; resetting to the zero value" {
+		t.Fatalf("Unexpected warnings %q", warnings)
+	}
+
+	set()
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+	unset()
+	os.Setenv("TEST_UNSET_LEVEL", "warn")
+	if err := DecodeWithOptions(&tc, WithUnset(UnsetZero)); err != nil {
+		t.Fatal(err)
+	}
+	expected = testConfigUnset{Host: "", Port: 8080, Level: "warn", Workers: 0}
+	if tc != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, tc)
+	}
+
+	set()
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+	unset()
+	os.Setenv("TEST_UNSET_WORKERS", "8")
+	if err := DecodeWithOptions(&tc, WithUnset(UnsetRetain)); err != nil {
+		t.Fatal(err)
+	}
+	expected = testConfigUnset{Host: "example.com", Port: 9090, Level: "debug", Workers: 8}
+	if tc != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, tc)
+	}
+
+	type badUnset struct {
+		Host string `env:"TEST_UNSET_HOST,unset=forget"`
+	}
+	if err := ValidateStruct(&badUnset{}); err == nil || !strings.Contains(err.Error(), `unknown unset policy "forget"`) {
+		t.Fatalf("Expected an unknown policy error, got %v", err)
+	}
+	if UnsetRetain.String() != "retain" {
+		t.Fatalf("Unexpected name %s", UnsetRetain)
+	}
+}
@@ -0,0 +1,292 @@
+package envdecode
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// envField is a tagged field found by walking a struct type.
+type envField struct {
+	path string
+	sf   reflect.StructField
+	opts tagOptions
+}
+
+// envFields returns the tagged fields of the struct type t, and of the
+// structs nested within it, that read an environment variable,
+// compose or template their value from several, or capture others.
+func (d *decodeState) envFields(t reflect.Type) []envField {
+	defer func(namePrefix string) { d.namePrefix = namePrefix }(d.namePrefix)
+
+	var fields []envField
+	base := d.namePrefix
+	prefixes := map[string]string{"": d.envconfigPrefix}
+	walkFields(t, func(parents []reflect.StructField, sf reflect.StructField, nested bool) {
+		parent := ""
+		if n := len(parents); n > 0 {
+			parent = fieldPath(parents[:n-1], parents[n-1])
+		}
+		prefix := prefixes[parent]
+		path := fieldPath(parents, sf)
+
+		if nested {
+			prefixes[path] = d.nestedPrefix(sf, prefix)
+			return
+		}
+		if sf.PkgPath != "" && sf.Anonymous {
+			return
+		}
+		d.namePrefix = base + namePrefix(parents)
+		// Names that can't be expanded are among opts.problems.
+		opts, ok, _ := d.fieldTag(sf, prefix)
+		if !ok || (opts.name == "" && opts.compose == "" && opts.template == "" && opts.capture == "" && !opts.remaining) {
+			return
+		}
+		fields = append(fields, envField{path: path, sf: sf, opts: opts})
+	})
+	return fields
+}
+
+// A Conflict describes an environment variable read by fields of
+// incompatible types, which would each interpret its value differently.
+type Conflict struct {
+	EnvVar string
+	Fields []string // paths of the fields reading EnvVar
+	Types  []string // types of the fields, in the same order
+}
+
+func (c *Conflict) String() string {
+	fields := make([]string, len(c.Fields))
+	for i := range c.Fields {
+		fields[i] = fmt.Sprintf("%s (%s)", c.Fields[i], c.Types[i])
+	}
+	return fmt.Sprintf("%s is read by fields of different types: %s", c.EnvVar, strings.Join(fields, ", "))
+}
+
+// Conflicts inspects the struct type of target and reports the
+// environment variables that are read by fields of different types,
+// such as a bool in one nested struct and an int in another, sorted by
+// variable name.  A pointer is compatible with the type it points to.
+// target must be a struct or a pointer to one; only its type is
+// examined.
+func Conflicts(target interface{}, opts ...Option) ([]*Conflict, error) {
+	t, err := structType(target)
+	if err != nil {
+		return nil, err
+	}
+
+	d := newDecodeState(opts)
+	byName := map[string][]envField{}
+	var names []string
+	for _, f := range d.envFields(t) {
+		if f.opts.name == "" {
+			continue
+		}
+		if _, ok := byName[f.opts.name]; !ok {
+			names = append(names, f.opts.name)
+		}
+		byName[f.opts.name] = append(byName[f.opts.name], f)
+	}
+	sort.Strings(names)
+
+	var conflicts []*Conflict
+	for _, name := range names {
+		fields := byName[name]
+		conflict := false
+		for _, f := range fields[1:] {
+			if derefType(f.sf.Type) != derefType(fields[0].sf.Type) {
+				conflict = true
+				break
+			}
+		}
+		if !conflict {
+			continue
+		}
+
+		c := &Conflict{EnvVar: name}
+		for _, f := range fields {
+			c.Fields = append(c.Fields, f.path)
+			c.Types = append(c.Types, f.sf.Type.String())
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, nil
+}
+
+// derefType returns the type t points to, or t itself if it is not a
+// pointer.
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// A TagError describes a problem with the tags of a struct field.
+type TagError struct {
+	Field   string
+	EnvVar  string
+	Message string
+}
+
+func (e *TagError) Error() string {
+	if e.EnvVar == "" {
+		return fmt.Sprintf("envdecode: %s: %s", e.Field, e.Message)
+	}
+	return fmt.Sprintf("envdecode: %s (%s): %s", e.Field, e.EnvVar, e.Message)
+}
+
+// TagErrors is the error returned by ValidateStruct, listing every
+// problem found.
+type TagErrors []*TagError
+
+func (e TagErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// ValidateStruct checks the tags of the struct type of target without
+// consulting the environment: that every option is understood, that
+// defaults can be converted to their field's type, that no variable is
+// read by more than one field and that no field is both required and
+// defaulted, under any profile.  It is meant to be called from tests
+// or init so that tag mistakes are caught before deployment.  The
+// result is nil or a TagErrors listing every problem.  target must be
+// a struct or a pointer to one; only its type is examined.
+func ValidateStruct(target interface{}, opts ...Option) error {
+	t, err := structType(target)
+	if err != nil {
+		return err
+	}
+
+	d := newDecodeState(opts)
+	var errs TagErrors
+	report := func(f envField, format string, args ...interface{}) {
+		errs = append(errs, &TagError{
+			Field:   f.path,
+			EnvVar:  f.opts.name,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	fields := d.envFields(t)
+	errs = append(errs, duplicates(fields)...)
+	for _, f := range fields {
+		for _, p := range f.opts.problems {
+			report(f, "%s", p)
+		}
+		if (f.opts.capture != "" || f.opts.remaining) && f.sf.Type != stringMapType {
+			report(f, "%s field must be a map[string]string, not %s", f.opts.captureOption(), f.sf.Type)
+		}
+		if f.opts.csv && !isCSVType(f.sf.Type) {
+			report(f, "csv requires a slice of structs, not %s", f.sf.Type)
+		}
+		if f.opts.dynamic && !isAtomicType(derefType(f.sf.Type)) {
+			report(f, "dynamic field of type %s is not of a sync/atomic type", f.sf.Type)
+		}
+		for _, name := range f.opts.transforms {
+			if transform(name) == nil {
+				report(f, "unknown transform %q", name)
+			}
+		}
+
+		for _, profile := range f.opts.profiles() {
+			popts := f.opts.forProfile(profile)
+			in := ""
+			if profile != "" {
+				in = fmt.Sprintf(" in profile %q", profile)
+			}
+			if popts.required && popts.hasDefault {
+				report(f, "both required and defaulted%s", in)
+			}
+			def := literalDefault(popts.defaultValue)
+			if d.windowsExpansion && strings.Contains(def, "%") {
+				// The default depends on other variables.
+				def = ""
+			}
+			if def != "" && !popts.transformed() {
+				v := reflect.New(f.sf.Type).Elem()
+				if err := d.decodeValue(v, def, popts, true); err != nil {
+					report(f, "invalid default%s %q: %v", in, def, err)
+				}
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// duplicates reports the fields reading a variable already read by an
+// earlier field, unless every field reading it is marked "shared".
+func duplicates(fields []envField) []*TagError {
+	byName := map[string][]envField{}
+	for _, f := range fields {
+		byName[f.opts.name] = append(byName[f.opts.name], f)
+	}
+
+	var errs []*TagError
+	for _, f := range fields {
+		readers := byName[f.opts.name]
+		if f.opts.name == "" || len(readers) < 2 || readers[0].path == f.path {
+			continue
+		}
+		shared := true
+		for _, r := range readers {
+			shared = shared && r.opts.shared
+		}
+		if !shared {
+			errs = append(errs, &TagError{
+				Field:   f.path,
+				EnvVar:  f.opts.name,
+				Message: fmt.Sprintf("variable is also read by %s; mark the fields \"shared\" if intended", readers[0].path),
+			})
+		}
+	}
+	return errs
+}
+
+// transformed reports whether values are read from a file, decoded,
+// decrypted, decompressed or transformed before they are converted to
+// the field's type, or are unmarshaled as documents or CSV.
+func (opts tagOptions) transformed() bool {
+	return opts.loadFile || opts.base64 || opts.encrypted || opts.gzip || opts.json || opts.yaml || opts.csv || opts.transforms != nil
+}
+
+// profiles returns "" followed by the names of the profiles opts has
+// scoped settings for, sorted.
+func (opts tagOptions) profiles() []string {
+	var names []string
+	for p := range opts.profileDefaults {
+		names = append(names, p)
+	}
+	for p := range opts.profileRequired {
+		if _, ok := opts.profileDefaults[p]; !ok {
+			names = append(names, p)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{""}, names...)
+}
+
+// literalDefault returns the value a default takes when none of the
+// variables or fields it refers to are set.
+func literalDefault(def string) string {
+	if !isDefaultReference(def) {
+		return unescapeDefault(def)
+	}
+	chain := strings.Split(def, "|")
+	for i, link := range chain {
+		if !isDefaultReference(link) {
+			return unescapeDefault(strings.Join(chain[i:], "|"))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package envdecode
+
+import (
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testConfigConflicts struct {
+	Bool    bool   `env:"TEST_BOOL"`
+	BoolPtr *bool  `env:"TEST_BOOL"`
+	Port    string `env:"TEST_PORT"`
+
+	Nested struct {
+		Port int    `env:"TEST_PORT"`
+		Host string `env:"TEST_HOST"`
+	}
+	Other struct {
+		Host string `env:"TEST_HOST"`
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	conflicts, err := Conflicts(&testConfigConflicts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*Conflict{
+		{
+			EnvVar: "TEST_PORT",
+			Fields: []string{"Port", "Nested.Port"},
+			Types:  []string{"string", "int"},
+		},
+	}
+	if !reflect.DeepEqual(conflicts, expected) {
+		for _, c := range conflicts {
+			t.Log(c)
+		}
+		t.Fatalf("Expected one conflict for TEST_PORT, got %d", len(conflicts))
+	}
+
+	if _, err := Conflicts(42); err != ErrInvalidTarget {
+		t.Fatalf("Expected ErrInvalidTarget, got %v", err)
+	}
+}
+
+type testConfigValidateStruct struct {
+	Good     int           `env:"TEST_VS_GOOD,default=1"`
+	BadInt   int           `env:"TEST_VS_BAD_INT,default=one"`
+	BadDur   time.Duration `env:"TEST_VS_BAD_DUR,default=$TEST_VS_OTHER|soon"`
+	Both     string        `env:"TEST_VS_BOTH,required,default=x"`
+	Profiled string        `env:"TEST_VS_PROFILED,default=x,required@production,default@production=y"`
+	Unknown  string        `env:"TEST_VS_UNKNOWN,requried,maxsize=big"`
+	BadReq   string        `env:"TEST_VS_BAD_REQ" envRequired:"yes"`
+	Chained  string        `env:"TEST_VS_CHAINED,default=$TEST_VS_OTHER"`
+
+	Nested struct {
+		Good int `env:"TEST_VS_GOOD"`
+	}
+}
+
+func TestValidateStruct(t *testing.T) {
+	err := ValidateStruct(&testConfigValidateStruct{})
+	errs, ok := err.(TagErrors)
+	if !ok {
+		t.Fatalf("Expected TagErrors, got %v", err)
+	}
+
+	have := map[string]int{}
+	for _, e := range errs {
+		have[e.Field]++
+	}
+	expected := map[string]int{
+		"BadInt":      1,
+		"BadDur":      1,
+		"Both":        1,
+		"Profiled":    1,
+		"Unknown":     2,
+		"BadReq":      1,
+		"Nested.Good": 1,
+	}
+	if !reflect.DeepEqual(have, expected) {
+		t.Log(err)
+		t.Fatalf("Expected problems %v, got %v", expected, have)
+	}
+
+	var tc struct {
+		Int      int      `env:"TEST_VS_INT,default=1"`
+		URL      *url.URL `env:"TEST_VS_URL,default=https://example.com"`
+		Required string   `env:"TEST_VS_REQUIRED,required"`
+	}
+	if err := ValidateStruct(&tc); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := ValidateStruct(nil); err != ErrInvalidTarget {
+		t.Fatalf("Expected ErrInvalidTarget, got %v", err)
+	}
+}
+
+type testConfigDuplicates struct {
+	Host string `env:"TEST_DUP_HOST"`
+	Port int    `env:"TEST_DUP_PORT,shared"`
+
+	Nested struct {
+		Host string `env:"TEST_DUP_HOST"`
+		Port int    `env:"TEST_DUP_PORT,shared"`
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	os.Setenv("TEST_DUP_HOST", "localhost")
+	defer os.Unsetenv("TEST_DUP_HOST")
+
+	var tc testConfigDuplicates
+	if err := Decode(&tc); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Host != "localhost" || tc.Nested.Host != "localhost" {
+		t.Fatalf("Expected both hosts to be decoded, got %q and %q", tc.Host, tc.Nested.Host)
+	}
+
+	err := StrictDecode(&tc)
+	terr, ok := err.(*TagError)
+	if !ok || terr.Field != "Nested.Host" || terr.EnvVar != "TEST_DUP_HOST" {
+		t.Fatalf("Expected a duplicate error for Nested.Host, got %v", err)
+	}
+
+	errs, _ := ValidateStruct(&tc).(TagErrors)
+	if len(errs) != 1 || errs[0].Field != "Nested.Host" {
+		t.Fatalf("Expected one duplicate error for Nested.Host, got %v", errs)
+	}
+
+	var shared struct {
+		A string `env:"TEST_DUP_HOST,shared"`
+		B string `env:"TEST_DUP_HOST,shared"`
+	}
+	if err := StrictDecode(&shared); err != nil {
+		t.Fatalf("Expected shared variables to be allowed, got %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package envdecode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Warning describes a questionable value that was nonetheless
+// accepted, such as a secret left at its default, which is worth
+// logging but not worth failing over.
+type Warning struct {
+	Field   string // path of the field, such as "Database.Password"
+	EnvVar  string // variable the field reads
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("envdecode: %s (%s): %s", w.Field, w.EnvVar, w.Message)
+}
+
+// WithWarnings calls fn with each warning raised while decoding:
+//
+//   - a variable found under its envconfig fallback name rather than
+//     its prefixed one
+//   - a variable found under its old name, given to WithRenames
+//   - a field reset to its zero value or retained because its variable
+//     is unset, see WithUnset
+//   - a secret given its default value
+//   - empty slice elements, as in "a;;b", which are ignored
+func WithWarnings(fn func(Warning)) Option {
+	return func(o *options) {
+		o.warnings = fn
+	}
+}
+
+// warn raises a warning about the field being decoded, with options
+// opts, if warnings were asked for.
+func (d *decodeState) warn(opts tagOptions, format string, args ...interface{}) {
+	if d.warnings == nil {
+		return
+	}
+	d.warnings(Warning{Field: d.field, EnvVar: opts.name, Message: fmt.Sprintf(format, args...)})
+}
+
+// hasEmptyElements reports whether any of the elements of a slice value
+// are empty.
+func hasEmptyElements(elems []string) bool {
+	for _, e := range elems {
+		if strings.TrimSpace(e) == "" {
+			return true
+		}
+	}
+	return false
+}
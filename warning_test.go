@@ -0,0 +1,61 @@
+package envdecode
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestWithWarnings(t *testing.T) {
+	os.Setenv("TEST_WARN_HOSTS", "a;;b;")
+	os.Setenv("TEST_WARN_PORTS", "80;443")
+	defer os.Unsetenv("TEST_WARN_HOSTS")
+	defer os.Unsetenv("TEST_WARN_PORTS")
+
+	var tc struct {
+		Hosts    []string `env:"TEST_WARN_HOSTS"`
+		Ports    []int    `env:"TEST_WARN_PORTS"`
+		Password string   `env:"TEST_WARN_PASSWORD,secret,default=changeme"`
+		Token    string   `env:"TEST_WARN_TOKEN,secret"`
+	}
+
+	var warnings []string
+	if err := DecodeWithOptions(&tc, WithWarnings(func(w Warning) {
+		warnings = append(warnings, w.String())
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`envdecode: Hosts (TEST_WARN_HOSTS): empty elements of "a;;b;" are ignored`,
+		`envdecode: Password (TEST_WARN_PASSWORD): secret is using its default value`,
+	}
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Fatalf("Expected %q, got %q", expected, warnings)
+	}
+	if len(tc.Hosts) != 2 {
+		t.Fatalf("Expected the warnings not to change decoding, got %v", tc.Hosts)
+	}
+}
+
+func TestWithWarningsEnvconfig(t *testing.T) {
+	os.Setenv("TEST_WARN_PORT", "8080")
+	defer os.Unsetenv("TEST_WARN_PORT")
+
+	var tc struct {
+		Port int `envconfig:"TEST_WARN_PORT"`
+	}
+
+	var warnings []Warning
+	if err := DecodeWithOptions(&tc, WithEnvconfigCompat("app"), WithWarnings(func(w Warning) {
+		warnings = append(warnings, w)
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Port != 8080 {
+		t.Fatalf("Expected 8080, got %d", tc.Port)
+	}
+	if len(warnings) != 1 || warnings[0] != (Warning{Field: "Port", EnvVar: "APP_TEST_WARN_PORT", Message: "APP_TEST_WARN_PORT is not set; using TEST_WARN_PORT instead"}) {
+		t.Fatalf("Unexpected warnings %+v", warnings)
+	}
+}